@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	cli "github.com/urfave/cli/v3"
+
+	"github.com/neox5/snp/internal/reader"
+	"github.com/neox5/snp/internal/snapdiff"
+	"github.com/neox5/snp/internal/snapshot"
+)
+
+// checkCommand verifies that a committed snapshot matches the tree
+func checkCommand() *cli.Command {
+	flags := []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "files-only",
+			Usage: "Compare only file contents, ignoring the summary and preamble sections",
+		},
+		&cli.BoolFlag{
+			Name:  "git",
+			Usage: "Also compare the git log, status, diff and changes sections, which change with every commit",
+		},
+	}
+
+	return &cli.Command{
+		Name:                      "check",
+		Usage:                     "Exit with status 1 if a snapshot no longer matches the directory it was taken from",
+		ArgsUsage:                 "[SNAPSHOT] [DIRECTORY]",
+		Flags:                     append(flags, redactFlags()...),
+		DisableSliceFlagSeparator: true,
+		Action: func(ctx context.Context, c *cli.Command) error {
+			if c.NArg() > 2 {
+				return fmt.Errorf("check takes at most a snapshot and a directory")
+			}
+			snapPath := snapshot.DefaultOutputName
+			if c.NArg() > 0 {
+				snapPath = c.Args().Get(0)
+			}
+
+			old, err := reader.ReadFile(snapPath)
+			if errors.Is(err, reader.ErrSplitPart) {
+				return fmt.Errorf("%s: checking split snapshots is not supported", snapPath)
+			}
+			if err != nil {
+				return err
+			}
+
+			args, err := splitOptions(old.Fields["Options"])
+			if err != nil {
+				return err
+			}
+			cfg, err := configFromOptions(ctx, args, sourceDirArg(c, 1))
+			if err != nil {
+				return err
+			}
+			// The snapshot excludes itself, as it did when it was written
+			cfg.OutputPath = snapPath
+			// Redaction rules are not recorded and are passed again
+			if cfg.RedactRules, err = redactRules(c); err != nil {
+				return err
+			}
+
+			absSourceDir, absOutput, err := snapshot.ValidateAndResolve(cfg)
+			if err != nil {
+				return err
+			}
+			snap, err := snapshot.Build(ctx, cfg, absSourceDir, absOutput)
+			if err != nil {
+				return err
+			}
+			if len(snap.Parts) > 0 {
+				return fmt.Errorf("%s: checking split snapshots is not supported", snapPath)
+			}
+
+			var buf bytes.Buffer
+			if _, err := snap.WriteTo(&buf); err != nil {
+				return err
+			}
+			cur, err := reader.Parse(&buf)
+			if err != nil {
+				return err
+			}
+
+			res := snapdiff.Compare(snapPath, old, absSourceDir, cur, 0)
+			withGit := c.Bool("git")
+			var report []string
+			if !c.Bool("files-only") {
+				report = append(report, staleSections(old, cur, withGit)...)
+			}
+			report = append(report, res.FileList()...)
+
+			if len(report) == 0 && (c.Bool("files-only") || sameLayout(old, cur, withGit)) {
+				fmt.Printf("%s is up to date\n", snapPath)
+				return nil
+			}
+
+			fmt.Printf("%s is stale:\n", snapPath)
+			for _, line := range report {
+				fmt.Printf("  %s\n", line)
+			}
+			if len(report) == 0 {
+				fmt.Println("  (layout differs)")
+			}
+			return cli.Exit("", 1)
+		},
+	}
+}
+
+// staleSections reports summary fields and preamble sections that differ.
+// The timestamp and the file index, whose changes show up per file, are
+// skipped, as are the git sections unless withGit is set.
+func staleSections(old, cur *reader.Snapshot, withGit bool) []string {
+	var lines []string
+
+	for _, key := range []string{"Revision", "Suspicious Unicode", "Options", "Token budget", "Parts"} {
+		if old.Fields[key] != cur.Fields[key] {
+			lines = append(lines, "~  summary: "+key)
+		}
+	}
+
+	titles := make(map[string]bool)
+	var ordered []string
+	for _, s := range append(append([]reader.Section(nil), old.Sections...), cur.Sections...) {
+		if !titles[s.Title] {
+			titles[s.Title] = true
+			ordered = append(ordered, s.Title)
+		}
+	}
+
+	for _, title := range ordered {
+		if title == "File Index" || (!withGit && gitSection(title)) {
+			continue
+		}
+		a, b := section(old, title), section(cur, title)
+		switch {
+		case a == nil:
+			lines = append(lines, "A  section: "+title)
+		case b == nil:
+			lines = append(lines, "D  section: "+title)
+		case strings.Join(a.Lines, "\n") != strings.Join(b.Lines, "\n"):
+			lines = append(lines, "M  section: "+title)
+		}
+	}
+
+	return lines
+}
+
+// gitSection reports whether a preamble section shows git history or the
+// working tree state. Committing, even the snapshot itself, changes them.
+func gitSection(title string) bool {
+	return strings.HasPrefix(title, "Git ") || strings.HasPrefix(title, "Changes since ")
+}
+
+// section returns the preamble section with exactly this title
+func section(s *reader.Snapshot, title string) *reader.Section {
+	for i := range s.Sections {
+		if s.Sections[i].Title == title {
+			return &s.Sections[i]
+		}
+	}
+	return nil
+}
+
+// sameLayout reports whether two snapshots match line by line, apart from
+// the timestamp. Without withGit, git sections may differ, shifting line
+// numbers and changing totals, so the summary without those fields and the
+// file contents are compared instead; sections and files are compared by
+// the caller.
+func sameLayout(old, cur *reader.Snapshot, withGit bool) bool {
+	if withGit {
+		return sameIgnoringTimestamp(old.Lines, cur.Lines)
+	}
+	return slices.Equal(stableSummary(old), stableSummary(cur)) &&
+		slices.Equal(contentLines(old), contentLines(cur))
+}
+
+// stableSummary returns the summary lines that do not depend on the git
+// sections
+func stableSummary(s *reader.Snapshot) []string {
+	var lines []string
+	for _, line := range s.Summary {
+		key, _, _ := strings.Cut(line, ": ")
+		switch key {
+		case "Generated", "Total lines", "Total tokens", "Digest":
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// contentLines returns the lines from the first file header on
+func contentLines(s *reader.Snapshot) []string {
+	start := len(s.Lines)
+	for _, f := range s.Files {
+		start = min(start, f.StartLine-2)
+	}
+	return s.Lines[start:]
+}
+
+// sameIgnoringTimestamp compares two snapshots line by line, skipping the
+// "Generated:" line
+func sameIgnoringTimestamp(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] && !(strings.HasPrefix(a[i], "Generated: ") && strings.HasPrefix(b[i], "Generated: ")) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheck_CommittedSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	git(t, dir, "init", "-q")
+	writeFiles(t, dir, map[string]string{"main.go": "package main\n", "README.md": "# demo\n"})
+	git(t, dir, "add", "-A")
+	git(t, dir, "commit", "-q", "-m", "initial")
+
+	if out, code := runSnp(t, dir, "--git-diff"); code != 0 {
+		t.Fatalf("snp: exit %d\n%s", code, out)
+	}
+	git(t, dir, "add", "-A")
+	git(t, dir, "commit", "-q", "-m", "add snapshot")
+
+	// Committing the snapshot changes its git log section only
+	if out, code := runSnp(t, dir, "check"); code != 0 || !strings.Contains(out, "is up to date") {
+		t.Errorf("check after commit: exit %d\n%s", code, out)
+	}
+	out, code := runSnp(t, dir, "check", "--git")
+	if code != 1 || !strings.Contains(out, "M  section: Git Log") {
+		t.Errorf("check --git after commit: exit %d\n%s", code, out)
+	}
+
+	// Content changes are still reported
+	writeFiles(t, dir, map[string]string{"main.go": "package main\n\nfunc main() {}\n"})
+	if out, code := runSnp(t, dir, "check"); code != 1 || !strings.Contains(out, "M  main.go") {
+		t.Errorf("check after edit: exit %d\n%s", code, out)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	cli "github.com/urfave/cli/v3"
+
+	"github.com/neox5/snp/internal/reader"
+	"github.com/neox5/snp/internal/snapdiff"
+)
+
+// diffCommand compares two snapshot files
+func diffCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "diff",
+		Usage:     "Compare two snapshots: added, removed and modified files with unified diffs",
+		ArgsUsage: "OLD.snp NEW.snp",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "json",
+				Usage: "Print the comparison as JSON",
+			},
+			&cli.IntFlag{
+				Name:  "context",
+				Usage: "Number of context lines in unified diffs",
+				Value: snapdiff.DefaultContext,
+			},
+			&cli.BoolFlag{
+				Name:  "exit-code",
+				Usage: "Exit with status 1 if the snapshots differ",
+			},
+		},
+		Action: func(ctx context.Context, c *cli.Command) error {
+			if c.NArg() != 2 {
+				return fmt.Errorf("diff requires two snapshot files")
+			}
+			oldPath, newPath := c.Args().Get(0), c.Args().Get(1)
+
+			oldSnap, err := reader.ReadFile(oldPath)
+			if err != nil {
+				return err
+			}
+			newSnap, err := reader.ReadFile(newPath)
+			if err != nil {
+				return err
+			}
+
+			res := snapdiff.Compare(oldPath, oldSnap, newPath, newSnap, max(c.Int("context"), 0))
+
+			if c.Bool("json") {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				if err := enc.Encode(res); err != nil {
+					return err
+				}
+			} else {
+				for _, line := range res.Lines() {
+					fmt.Println(line)
+				}
+			}
+
+			if c.Bool("exit-code") && res.Changed() {
+				return cli.Exit("", 1)
+			}
+			return nil
+		},
+	}
+}
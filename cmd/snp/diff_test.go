@@ -0,0 +1,35 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDiff_ExitCode(t *testing.T) {
+	dir, out := t.TempDir(), t.TempDir()
+	oldPath, newPath := filepath.Join(out, "old.snp"), filepath.Join(out, "new.snp")
+	writeFiles(t, dir, map[string]string{"main.go": "package main\n"})
+	if out, code := runSnp(t, dir, "--output", oldPath); code != 0 {
+		t.Fatalf("snp: exit %d\n%s", code, out)
+	}
+	writeFiles(t, dir, map[string]string{"main.go": "package main\n\nfunc main() {}\n"})
+	if out, code := runSnp(t, dir, "--output", newPath); code != 0 {
+		t.Fatalf("snp: exit %d\n%s", code, out)
+	}
+
+	tests := []struct {
+		args []string
+		code int
+	}{
+		{[]string{"diff", oldPath, newPath}, 0},
+		{[]string{"diff", "--exit-code", oldPath, newPath}, 1},
+		{[]string{"diff", "--exit-code", oldPath, oldPath}, 0},
+	}
+	for _, tt := range tests {
+		out, code := runSnp(t, dir, tt.args...)
+		if code != tt.code {
+			t.Errorf("snp %s: exit %d, want %d\n%s", strings.Join(tt.args, " "), code, tt.code, out)
+		}
+	}
+}
@@ -3,13 +3,20 @@ package main
 import (
 	"context"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"slices"
 	"time"
 
 	cli "github.com/urfave/cli/v3"
 
+	"github.com/neox5/snp/internal/cache"
+	"github.com/neox5/snp/internal/file"
+	"github.com/neox5/snp/internal/gitlog"
+	"github.com/neox5/snp/internal/secrets"
 	"github.com/neox5/snp/internal/snapshot"
+	"github.com/neox5/snp/internal/token"
 	"github.com/neox5/snp/internal/version"
 )
 
@@ -22,104 +29,380 @@ func main() {
 
 Concatenates readable source/text files into one snapshot file.
 If DIRECTORY is omitted, '.' is used.`,
-		Flags: []cli.Flag{
-			&cli.StringFlag{
-				Name:  "output",
-				Usage: "Set output file path",
-				Value: snapshot.DefaultOutputName,
-			},
-			&cli.StringSliceFlag{
-				Name:  "include",
-				Usage: "Include files matching this glob pattern (repeatable)",
-			},
-			&cli.StringSliceFlag{
-				Name:  "exclude",
-				Usage: "Exclude files matching this glob pattern (repeatable)",
-			},
-			&cli.BoolFlag{
-				Name:  "exclude-git-log",
-				Usage: "Omit the Git log section (included by default)",
-			},
-			&cli.BoolFlag{
-				Name:  "dry-run",
-				Usage: "Print files that would be included without creating output",
-			},
-			&cli.BoolFlag{
-				Name:  "silent",
-				Usage: "Suppress all output (exit codes only)",
-			},
-			&cli.StringSliceFlag{
-				Name:  "force-text",
-				Usage: "Force files matching glob pattern to be treated as text (repeatable)",
-			},
-			&cli.StringSliceFlag{
-				Name:  "force-binary",
-				Usage: "Force files matching glob pattern to be treated as binary (repeatable)",
-			},
-		},
+		Flags:     snapshotFlags(),
 		ArgsUsage: "[DIRECTORY]",
-		Action: func(ctx context.Context, c *cli.Command) error {
-			sourceDir := "."
-			if c.NArg() > 0 {
-				sourceDir = c.Args().First()
-			}
+		Action:    runSnapshot,
+		// Repeatable flags are not split on commas, which globs like
+		// "*.{go,md}" and redaction rules contain
+		DisableSliceFlagSeparator: true,
+		Commands: []*cli.Command{
+			statsCommand(),
+			diffCommand(),
+			checkCommand(),
+			watchCommand(),
+			verifyCommand(),
+			signCommand(),
+			verifySignatureCommand(),
+		},
+	}
 
-			silent := c.Bool("silent")
-
-			cfg := snapshot.Config{
-				SourceDir:           sourceDir,
-				OutputPath:          c.String("output"),
-				IncludePatterns:     c.StringSlice("include"),
-				ExcludePatterns:     c.StringSlice("exclude"),
-				IncludeGitLog:       !c.Bool("exclude-git-log"),
-				DryRun:              c.Bool("dry-run"),
-				ForceTextPatterns:   c.StringSlice("force-text"),
-				ForceBinaryPatterns: c.StringSlice("force-binary"),
-			}
+	if err := app.Run(context.Background(), os.Args); err != nil {
+		log.Fatalf("snp: %v", err)
+	}
+}
 
-			absSourceDir, absOutput, err := snapshot.ValidateAndResolve(cfg)
-			if err != nil {
-				return err
-			}
+// filterFlags returns the flags that select which files are collected
+func filterFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:  "include",
+			Usage: "Include files matching this glob pattern (repeatable)",
+		},
+		&cli.StringSliceFlag{
+			Name:  "exclude",
+			Usage: "Exclude files matching this glob pattern (repeatable)",
+		},
+		&cli.StringSliceFlag{
+			Name:  "force-text",
+			Usage: "Force files matching glob pattern to be treated as text (repeatable)",
+		},
+		&cli.StringSliceFlag{
+			Name:  "force-binary",
+			Usage: "Force files matching glob pattern to be treated as binary (repeatable)",
+		},
+		&cli.StringSliceFlag{
+			Name:  "force-encoding",
+			Usage: "Decode files matching glob pattern as text in this encoding, e.g. legacy/**=latin-1 (repeatable)",
+		},
+		&cli.StringFlag{
+			Name:  "submodules",
+			Usage: "Handle submodules and nested repositories: collapse (list only) or recurse (with their own .gitignore)",
+			Value: file.SubmodulesCollapse,
+		},
+	}
+}
 
-			start := time.Now()
+// redactFlags returns the flags adding custom redaction rules
+func redactFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:  "redact",
+			Usage: "Replace matches of a regex: [@GLOB,...:]REGEX[=>REPLACEMENT] (repeatable)",
+		},
+		&cli.StringFlag{
+			Name:  "redact-rules",
+			Usage: "Read redaction rules from this file, one per line",
+		},
+	}
+}
 
-			snap, err := snapshot.Build(ctx, cfg, absSourceDir, absOutput)
-			if err != nil {
-				return err
-			}
+// snapshotFlags returns the flags controlling snapshot content and output
+func snapshotFlags() []cli.Flag {
+	flags := []cli.Flag{
+		&cli.StringFlag{
+			Name:  "output",
+			Usage: "Set output file path, or - to write to stdout",
+			Value: snapshot.DefaultOutputName,
+		},
+		&cli.BoolFlag{
+			Name:  "exclude-git-log",
+			Usage: "Omit the Git log section (included by default)",
+		},
+		&cli.IntFlag{
+			Name:  "git-log-max",
+			Usage: "Limit the Git log to this many commits (0 = unlimited)",
+		},
+		&cli.StringFlag{
+			Name:  "git-log-since",
+			Usage: "Only show commits more recent than this date (e.g. \"2 weeks ago\", 2025-01-01)",
+		},
+		&cli.StringFlag{
+			Name:  "git-log-range",
+			Usage: "Only show commits in this revision range (e.g. v1.0..HEAD)",
+		},
+		&cli.BoolFlag{
+			Name:  "git-log-current-branch",
+			Usage: "Only show commits reachable from HEAD instead of all refs",
+		},
+		&cli.BoolFlag{
+			Name:  "git-log-full",
+			Usage: "Show full commit messages with author and date",
+		},
+		&cli.BoolFlag{
+			Name:  "git-diff",
+			Usage: "Add git status and staged/unstaged diff sections, and mark changed files in the index",
+		},
+		&cli.BoolFlag{
+			Name:  "git-meta",
+			Usage: "Annotate index entries with last commit, author, date, commit count and tracking status",
+		},
+		&cli.BoolFlag{
+			Name:  "blame",
+			Usage: "Prefix each text line with the commit and author that last touched it",
+		},
+		&cli.BoolFlag{
+			Name:  "blame-suffix",
+			Usage: "Like --blame, but append the annotation to the end of each line",
+		},
+		&cli.StringFlag{
+			Name:  "changed-since",
+			Usage: "Only include files changed between the merge base with this ref and the working tree",
+		},
+		&cli.BoolFlag{
+			Name:  "with-neighbors",
+			Usage: "With --changed-since, also include unchanged files in the same directories",
+		},
+		&cli.StringFlag{
+			Name:  "rev",
+			Usage: "Snapshot this git commit-ish from the object store instead of the working tree",
+		},
+		&cli.BoolFlag{
+			Name:  "tree",
+			Usage: "Add a directory tree section with per-directory file counts and sizes",
+		},
+		&cli.BoolFlag{
+			Name:  "tree-excluded",
+			Usage: "List excluded directories as collapsed tree entries (implies --tree)",
+		},
+		&cli.BoolFlag{
+			Name:  "stats",
+			Usage: "Add language and size statistics to the summary",
+		},
+		&cli.BoolFlag{
+			Name:  "compress",
+			Usage: "Write gzip-compressed output, appending .gz to the output path (implied by a .gz output path)",
+		},
+		&cli.BoolFlag{
+			Name:  "hashes",
+			Usage: "Add each file's SHA-256 to the file index and a digest of the snapshot to the summary (see snp verify)",
+		},
+		&cli.BoolFlag{
+			Name:  "escape-unicode",
+			Usage: "Show bidi controls, invisible characters and homoglyphs as \\uXXXX escapes",
+		},
+		&cli.BoolFlag{
+			Name:  "keep-secrets",
+			Usage: "Keep detected secrets (keys, tokens, .env values) instead of replacing them with placeholders",
+		},
+		&cli.BoolFlag{
+			Name:  "fail-on-secrets",
+			Usage: "Abort without writing output if secrets are detected",
+		},
+		&cli.BoolFlag{
+			Name:  "dry-run",
+			Usage: "Print files that would be included without creating output",
+		},
+		&cli.BoolFlag{
+			Name:  "no-cache",
+			Usage: "Re-read and re-analyze every file instead of using the content cache",
+		},
+		&cli.BoolFlag{
+			Name:  "silent",
+			Usage: "Suppress all output (exit codes only)",
+		},
+		&cli.StringFlag{
+			Name:  "tokenizer",
+			Usage: "Token estimator: heuristic or bpe",
+			Value: token.DefaultTokenizer,
+		},
+		&cli.IntFlag{
+			Name:  "max-tokens",
+			Usage: "Drop or truncate lowest-priority files until the snapshot fits this token budget (0 = unlimited)",
+		},
+		&cli.StringSliceFlag{
+			Name:  "priority",
+			Usage: "Emit files matching this glob pattern first; earlier patterns rank higher (repeatable)",
+		},
+		&cli.StringFlag{
+			Name:  "sort",
+			Usage: "Order files within a priority group: path, size, recency or depth",
+			Value: file.SortPath,
+		},
+		&cli.StringFlag{
+			Name:  "split-size",
+			Usage: "Split output into numbered parts of at most this size (e.g. 500KB, 5000lines, 30000tokens)",
+		},
+	}
 
-			if cfg.DryRun {
-				if !silent {
-					for _, f := range snap.Files {
-						fmt.Println(f.RelPath)
-					}
-				}
-				return nil
-			}
+	return slices.Concat(flags, redactFlags(), filterFlags())
+}
 
-			outFile, err := os.Create(absOutput)
-			if err != nil {
-				return fmt.Errorf("cannot create output file %q: %w", absOutput, err)
-			}
-			defer outFile.Close()
+// sourceDirArg returns the DIRECTORY argument at position i, or "."
+func sourceDirArg(c *cli.Command, i int) string {
+	if c.NArg() > i {
+		return c.Args().Get(i)
+	}
+	return "."
+}
 
-			if err := snap.WriteTo(outFile); err != nil {
-				return err
-			}
+// configFromFlags builds a snapshot config from the snapshot flags
+func configFromFlags(c *cli.Command, sourceDir string) (snapshot.Config, error) {
+	splitSize, err := snapshot.ParseSplitSize(c.String("split-size"))
+	if err != nil {
+		return snapshot.Config{}, err
+	}
 
-			elapsed := time.Since(start)
+	rules, err := redactRules(c)
+	if err != nil {
+		return snapshot.Config{}, err
+	}
 
-			if !silent {
-				fmt.Printf("Snapshot created: %s (%s)\n", absOutput, formatDuration(elapsed))
-			}
+	// Without a user cache directory, snapshots are built uncached
+	var cacheDir string
+	if !c.Bool("no-cache") {
+		cacheDir, _ = cache.DefaultDir()
+	}
 
-			return nil
+	return snapshot.Config{
+		SourceDir:       sourceDir,
+		OutputPath:      c.String("output"),
+		IncludePatterns: c.StringSlice("include"),
+		ExcludePatterns: c.StringSlice("exclude"),
+		IncludeGitLog:   !c.Bool("exclude-git-log"),
+		GitLogOptions: gitlog.Options{
+			MaxCount: c.Int("git-log-max"),
+			Since:    c.String("git-log-since"),
+			Range:    c.String("git-log-range"),
+			OnlyHEAD: c.Bool("git-log-current-branch"),
+			Full:     c.Bool("git-log-full"),
 		},
+		DryRun:                c.Bool("dry-run"),
+		ForceTextPatterns:     c.StringSlice("force-text"),
+		ForceBinaryPatterns:   c.StringSlice("force-binary"),
+		ForceEncodingPatterns: c.StringSlice("force-encoding"),
+		Submodules:            c.String("submodules"),
+		Tokenizer:             c.String("tokenizer"),
+		MaxTokens:             c.Int("max-tokens"),
+		SplitSize:             splitSize,
+		PriorityPatterns:      c.StringSlice("priority"),
+		SortBy:                c.String("sort"),
+		IncludeGitDiff:        c.Bool("git-diff"),
+		IncludeGitMeta:        c.Bool("git-meta"),
+		Blame:                 c.Bool("blame"),
+		BlameSuffix:           c.Bool("blame-suffix"),
+		ChangedSince:          c.String("changed-since"),
+		WithNeighbors:         c.Bool("with-neighbors"),
+		Rev:                   c.String("rev"),
+		IncludeTree:           c.Bool("tree"),
+		TreeExcluded:          c.Bool("tree-excluded"),
+		IncludeStats:          c.Bool("stats"),
+		IncludeHashes:         c.Bool("hashes"),
+		Compress:              c.Bool("compress"),
+		KeepSecrets:           c.Bool("keep-secrets"),
+		FailOnSecrets:         c.Bool("fail-on-secrets"),
+		RedactRules:           rules,
+		EscapeUnicode:         c.Bool("escape-unicode"),
+		Options:               recordedOptions(c),
+		CacheDir:              cacheDir,
+	}, nil
+}
+
+// redactRules parses the rules of the --redact-rules file and --redact flags
+func redactRules(c *cli.Command) ([]secrets.Rule, error) {
+	var rules []secrets.Rule
+	if path := c.String("redact-rules"); path != "" {
+		loaded, err := secrets.LoadRules(path)
+		if err != nil {
+			return nil, err
+		}
+		rules = loaded
 	}
+	for _, spec := range c.StringSlice("redact") {
+		r, err := secrets.ParseRule(spec)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
 
-	if err := app.Run(context.Background(), os.Args); err != nil {
-		log.Fatalf("snp: %v", err)
+// runSnapshot is the root action: build and write a snapshot
+func runSnapshot(ctx context.Context, c *cli.Command) error {
+	silent := c.Bool("silent")
+
+	cfg, err := configFromFlags(c, sourceDirArg(c, 0))
+	if err != nil {
+		return err
+	}
+
+	absSourceDir, absOutput, err := snapshot.ValidateAndResolve(cfg)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+
+	snap, err := snapshot.Build(ctx, cfg, absSourceDir, absOutput)
+	if err != nil {
+		return err
+	}
+	if !silent {
+		printWarnings(os.Stderr, snap.Warnings)
+	}
+
+	if cfg.DryRun {
+		if !silent {
+			for _, f := range snap.Files {
+				fmt.Println(f.RelPath)
+			}
+			printCuts(os.Stdout, snap.Cuts)
+			printSecrets(os.Stdout, snap.Secrets)
+			printUnicode(os.Stdout, snap)
+		}
+		return nil
+	}
+
+	paths, err := writeSnapshot(snap, absOutput)
+	if err != nil {
+		return err
+	}
+
+	// Keep stdout clean for the snapshot itself
+	status := os.Stdout
+	if absOutput == snapshot.Stdout {
+		status = os.Stderr
+	}
+	if !silent {
+		printCreated(status, paths, time.Since(start))
+		printCuts(status, snap.Cuts)
+		printSecrets(status, snap.Secrets)
+		printUnicode(status, snap)
+	}
+
+	return nil
+}
+
+// writeSnapshot writes snap to absOutput, or its parts next to it.
+// Returns the written paths.
+func writeSnapshot(snap *snapshot.Snapshot, absOutput string) ([]string, error) {
+	if len(snap.Parts) > 0 {
+		return snap.WriteParts(absOutput)
+	}
+	if absOutput == snapshot.Stdout {
+		if _, err := snap.WriteTo(os.Stdout); err != nil {
+			return nil, err
+		}
+		return []string{absOutput}, nil
+	}
+	if err := snapshot.WriteFile(absOutput, snap); err != nil {
+		return nil, err
+	}
+	return []string{absOutput}, nil
+}
+
+// printCreated reports the written snapshot or parts
+func printCreated(w io.Writer, paths []string, elapsed time.Duration) {
+	switch {
+	case len(paths) == 1 && paths[0] == snapshot.Stdout:
+		fmt.Fprintf(w, "Snapshot written to stdout (%s)\n", formatDuration(elapsed))
+	case len(paths) == 1:
+		fmt.Fprintf(w, "Snapshot created: %s (%s)\n", paths[0], formatDuration(elapsed))
+	default:
+		fmt.Fprintf(w, "Snapshot created: %d parts (%s)\n", len(paths), formatDuration(elapsed))
+		for _, p := range paths {
+			fmt.Fprintf(w, "  %s\n", p)
+		}
 	}
 }
 
@@ -131,3 +414,50 @@ func formatDuration(d time.Duration) string {
 	}
 	return fmt.Sprintf("%.1fs", d.Seconds())
 }
+
+// printWarnings reports problems that did not stop the build
+func printWarnings(w io.Writer, warnings []string) {
+	for _, msg := range warnings {
+		fmt.Fprintf(w, "snp: warning: %s\n", msg)
+	}
+}
+
+// printCuts reports files dropped or truncated to fit the token budget
+func printCuts(w io.Writer, cuts []snapshot.Cut) {
+	if len(cuts) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "Token budget: cut %d file(s)\n", len(cuts))
+	for _, c := range cuts {
+		fmt.Fprintf(w, "  %s\n", c)
+	}
+}
+
+// printSecrets reports secrets replaced by placeholders
+func printSecrets(w io.Writer, findings []secrets.Finding) {
+	if len(findings) == 0 {
+		return
+	}
+	files := make(map[string]bool)
+	for _, f := range findings {
+		files[f.Path] = true
+	}
+	fmt.Fprintf(w, "Secrets: redacted %d in %d file(s)\n", len(findings), len(files))
+	for _, f := range findings {
+		fmt.Fprintf(w, "  %s\n", f)
+	}
+}
+
+// printUnicode reports suspicious characters per file
+func printUnicode(w io.Writer, snap *snapshot.Snapshot) {
+	summary := snap.UnicodeSummary()
+	if summary == "" {
+		return
+	}
+	fmt.Fprintf(w, "Suspicious Unicode: %s\n", summary)
+	for _, f := range snap.Files {
+		for _, u := range f.Unicode {
+			fmt.Fprintf(w, "  %s:%s\n", f.RelPath, u)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	cli "github.com/urfave/cli/v3"
+
+	"github.com/neox5/snp/internal/snapshot"
+)
+
+// unrecordedFlags are left out of the recorded options: they do not affect
+// snapshot content, or, like redaction rules, would reveal what they hide
+var unrecordedFlags = map[string]bool{
+	"output":          true,
+	"dry-run":         true,
+	"silent":          true,
+	"no-cache":        true,
+	"fail-on-secrets": true,
+	"redact":          true,
+	"redact-rules":    true,
+}
+
+// recordedOptions returns the explicitly set content flags as arguments,
+// e.g. ["--tree", "--max-tokens=1000", `--git-log-since="2 weeks ago"`]
+func recordedOptions(c *cli.Command) []string {
+	var args []string
+	for _, flag := range snapshotFlags() {
+		name := flag.Names()[0]
+		if unrecordedFlags[name] || !c.IsSet(name) {
+			continue
+		}
+
+		switch flag.(type) {
+		case *cli.BoolFlag:
+			if c.Bool(name) {
+				args = append(args, "--"+name)
+			} else {
+				args = append(args, "--"+name+"=false")
+			}
+		case *cli.IntFlag:
+			args = append(args, fmt.Sprintf("--%s=%d", name, c.Int(name)))
+		case *cli.StringFlag:
+			args = append(args, "--"+name+"="+quoteArg(c.String(name)))
+		case *cli.StringSliceFlag:
+			for _, v := range c.StringSlice(name) {
+				args = append(args, "--"+name+"="+quoteArg(v))
+			}
+		}
+	}
+	return args
+}
+
+// quoteArg quotes values that would not survive splitting on spaces
+func quoteArg(s string) string {
+	if s == "" || strings.ContainsAny(s, " \t\"\\") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// splitOptions splits a recorded options line back into arguments,
+// unquoting Go-quoted values
+func splitOptions(line string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	inArg := false
+
+	for i := 0; i < len(line); {
+		switch ch := line[i]; {
+		case ch == ' ' || ch == '\t':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+			i++
+		case ch == '"':
+			quoted, err := strconv.QuotedPrefix(line[i:])
+			if err != nil {
+				return nil, fmt.Errorf("invalid quoting in options %q", line)
+			}
+			value, _ := strconv.Unquote(quoted)
+			current.WriteString(value)
+			inArg = true
+			i += len(quoted)
+		default:
+			current.WriteByte(ch)
+			inArg = true
+			i++
+		}
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
+
+// configFromOptions parses recorded options with the snapshot flags
+func configFromOptions(ctx context.Context, args []string, sourceDir string) (snapshot.Config, error) {
+	var cfg snapshot.Config
+	cmd := &cli.Command{
+		Name:                      "snp",
+		Flags:                     snapshotFlags(),
+		DisableSliceFlagSeparator: true,
+		Action: func(ctx context.Context, c *cli.Command) error {
+			var err error
+			cfg, err = configFromFlags(c, sourceDir)
+			return err
+		},
+	}
+
+	if err := cmd.Run(ctx, append([]string{"snp"}, args...)); err != nil {
+		return snapshot.Config{}, fmt.Errorf("invalid recorded options: %w", err)
+	}
+	return cfg, nil
+}
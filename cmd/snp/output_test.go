@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/neox5/snp/internal/reader"
+)
+
+func TestOutput_Stdout(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"main.go": "package main\n", "README.md": "# demo\n"})
+
+	tests := []struct {
+		args []string
+		gzip bool
+	}{
+		{[]string{"--output", "-"}, false},
+		{[]string{"--output", "-", "--compress"}, true},
+	}
+	for _, tt := range tests {
+		args := tt.args
+		stdout, stderr, code := runSnpStreams(t, dir, args...)
+		if code != 0 {
+			t.Fatalf("snp %s: exit %d\n%s", strings.Join(args, " "), code, stderr)
+		}
+		if got := bytes.HasPrefix(stdout, []byte{0x1f, 0x8b}); got != tt.gzip {
+			t.Errorf("snp %s: gzip output = %v, want %v", strings.Join(args, " "), got, tt.gzip)
+		}
+
+		snap, err := reader.Parse(bytes.NewReader(stdout))
+		if err != nil {
+			t.Fatalf("snp %s: parse stdout: %v", strings.Join(args, " "), err)
+		}
+		if len(snap.Files) != 2 || snap.File("main.go") == nil {
+			t.Errorf("snp %s: parsed %d files, want main.go and README.md", strings.Join(args, " "), len(snap.Files))
+		}
+		if !strings.Contains(stderr, "Snapshot written to stdout") {
+			t.Errorf("snp %s: status not on stderr: %q", strings.Join(args, " "), stderr)
+		}
+	}
+
+	// Parts cannot share stdout
+	stdout, stderr, code := runSnpStreams(t, dir, "--output", "-", "--split-size", "1KB")
+	if code == 0 || len(stdout) > 0 || !strings.Contains(stderr, "cannot be written to stdout") {
+		t.Errorf("--split-size to stdout: exit %d, stdout %q\n%s", code, stdout, stderr)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRedactFlag_Commas(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"docs/guide.txt": "password hunter2\n",
+		"notes.md":       "password hunter2\n",
+		"main.go":        "// password hunter2 xxxx\n",
+	})
+
+	// Neither the glob list nor the regex quantifier is split on commas
+	args := []string{"--redact", "@docs/**,*.md:hunter2=>[gone]", "--redact", "x{2,3}=>y", "--output", "-"}
+	out, code := runSnp(t, dir, args...)
+	if code != 0 {
+		t.Fatalf("snp: exit %d\n%s", code, out)
+	}
+	if strings.Count(out, "\npassword [gone]\n") != 2 {
+		t.Errorf("want docs/guide.txt and notes.md redacted:\n%s", out)
+	}
+	if !strings.Contains(out, "\n// password hunter2 yx\n") {
+		t.Errorf("want main.go redacted by x{2,3} only:\n%s", out)
+	}
+
+	// check parses the rules the same way
+	if out, code := runSnp(t, dir, args[:4]...); code != 0 {
+		t.Fatalf("snp: exit %d\n%s", code, out)
+	}
+	if out, code := runSnp(t, dir, append([]string{"check"}, args[:4]...)...); code != 0 {
+		t.Errorf("check: exit %d\n%s", code, out)
+	}
+}
+
+func TestRedactFlag_NotRecorded(t *testing.T) {
+	dir, rulesDir := t.TempDir(), t.TempDir()
+	writeFiles(t, dir, map[string]string{"main.go": "// see db.acme-internal.com for BigBankCorp\n"})
+	writeFiles(t, rulesDir, map[string]string{"rules.txt": "BigBankCorp=>CUST\n"})
+	rulesPath := filepath.Join(rulesDir, "rules.txt")
+
+	args := []string{"--redact", `acme-internal\.com=>example.com`, "--redact-rules", rulesPath}
+	out, code := runSnp(t, dir, append(args, "--output", "-")...)
+	if code != 0 {
+		t.Fatalf("snp: exit %d\n%s", code, out)
+	}
+	if !strings.Contains(out, "\n// see db.example.com for CUST\n") {
+		t.Errorf("want main.go redacted:\n%s", out)
+	}
+	for _, text := range []string{"acme-internal", "BigBankCorp", rulesPath} {
+		if strings.Contains(out, text) {
+			t.Errorf("output contains rule text %q:\n%s", text, out)
+		}
+	}
+
+	// check takes the rules from its own command line
+	if out, code := runSnp(t, dir, args...); code != 0 {
+		t.Fatalf("snp: exit %d\n%s", code, out)
+	}
+	if out, code := runSnp(t, dir, append([]string{"check"}, args...)...); code != 0 {
+		t.Errorf("check with rules: exit %d\n%s", code, out)
+	}
+	if out, code := runSnp(t, dir, "check"); code != 1 || !strings.Contains(out, "M  main.go") {
+		t.Errorf("check without rules: exit %d\n%s", code, out)
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"fmt"
+	"os"
+
+	cli "github.com/urfave/cli/v3"
+
+	"github.com/neox5/snp/internal/reader"
+	"github.com/neox5/snp/internal/signature"
+)
+
+// signCommand writes a detached signature next to a snapshot
+func signCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "sign",
+		Usage:     "Sign a snapshot created with --hashes, writing SNAPSHOT.sig",
+		ArgsUsage: "SNAPSHOT",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "key",
+				Usage: "Private key file, generated if missing (default: user config directory)",
+			},
+		},
+		Action: func(ctx context.Context, c *cli.Command) error {
+			if c.NArg() != 1 {
+				return fmt.Errorf("sign requires a snapshot file")
+			}
+			snapPath := c.Args().Get(0)
+
+			snap, err := readDigested(snapPath)
+			if err != nil {
+				return err
+			}
+
+			keyPath, err := keyPathFlag(c)
+			if err != nil {
+				return err
+			}
+			key, created, err := signature.LoadOrCreateKey(keyPath)
+			if err != nil {
+				return err
+			}
+			if created {
+				fmt.Printf("Generated signing key: %s\n", keyPath)
+				fmt.Printf("Public key: %s\n", signature.PublicKeyPath(keyPath))
+			}
+
+			sig := signature.Sign(key, signature.Payload(snap.Summary))
+			sigPath := signature.SidecarPath(snapPath)
+			if err := os.WriteFile(sigPath, sig.Marshal(), 0o644); err != nil {
+				return fmt.Errorf("cannot write signature: %w", err)
+			}
+
+			fmt.Printf("Signed %s with key %s\n", snapPath, signature.Fingerprint(sig.PublicKey))
+			fmt.Printf("Signature: %s\n", sigPath)
+			return nil
+		},
+	}
+}
+
+// verifySignatureCommand checks a snapshot against its detached signature
+func verifySignatureCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "verify-signature",
+		Usage:     "Check a snapshot's signature against a trusted public key",
+		ArgsUsage: "SNAPSHOT",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "signature",
+				Usage: "Signature file (default: SNAPSHOT.sig)",
+			},
+			&cli.StringFlag{
+				Name:  "public-key",
+				Usage: "Trusted public key file (default: the local signing key's)",
+			},
+			&cli.StringFlag{
+				Name:  "key",
+				Usage: "Local private key file whose public key is trusted by default",
+			},
+		},
+		Action: func(ctx context.Context, c *cli.Command) error {
+			if c.NArg() != 1 {
+				return fmt.Errorf("verify-signature requires a snapshot file")
+			}
+			snapPath := c.Args().Get(0)
+
+			trusted, err := trustedKey(c)
+			if err != nil {
+				return err
+			}
+
+			sigPath := c.String("signature")
+			if sigPath == "" {
+				sigPath = signature.SidecarPath(snapPath)
+			}
+			data, err := os.ReadFile(sigPath)
+			if err != nil {
+				return err
+			}
+			sig, err := signature.Parse(data)
+			if err != nil {
+				return fmt.Errorf("%s: %w", sigPath, err)
+			}
+
+			snap, err := readDigested(snapPath)
+			if err == nil {
+				err = sig.Verify(signature.Payload(snap.Summary), trusted)
+			}
+			if err != nil {
+				fmt.Printf("BAD signature for %s: %v\n", snapPath, err)
+				return cli.Exit("", 1)
+			}
+
+			fmt.Printf("Good signature for %s from key %s\n", snapPath, signature.Fingerprint(trusted))
+			if generated := snap.Fields["Generated"]; generated != "" {
+				fmt.Printf("Generated: %s\n", generated)
+			}
+			return nil
+		},
+	}
+}
+
+// readDigested reads a snapshot whose digest matches its content. The
+// digest ties the signed summary to the rest of the snapshot.
+func readDigested(path string) (*reader.Snapshot, error) {
+	snap, err := reader.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	digest, ok := snap.Fields["Digest"]
+	if !ok {
+		return nil, fmt.Errorf("%s has no digest; create it with --hashes", path)
+	}
+	if digest != "sha256:"+snap.Digest() {
+		return nil, fmt.Errorf("%s was modified after it was written (digest mismatch)", path)
+	}
+	return snap, nil
+}
+
+// keyPathFlag returns the --key path or the default key location
+func keyPathFlag(c *cli.Command) (string, error) {
+	if path := c.String("key"); path != "" {
+		return path, nil
+	}
+	path, err := signature.DefaultKeyPath()
+	if err != nil {
+		return "", fmt.Errorf("cannot locate signing key: %w", err)
+	}
+	return path, nil
+}
+
+// trustedKey returns the --public-key, or the public key of the local
+// signing key
+func trustedKey(c *cli.Command) (ed25519.PublicKey, error) {
+	if path := c.String("public-key"); path != "" {
+		return signature.LoadPublicKey(path)
+	}
+	keyPath, err := keyPathFlag(c)
+	if err != nil {
+		return nil, err
+	}
+	pub, err := signature.LoadPublicKey(signature.PublicKeyPath(keyPath))
+	if err != nil {
+		return nil, fmt.Errorf("no trusted key: %w (pass --public-key)", err)
+	}
+	return pub, nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	cli "github.com/urfave/cli/v3"
+
+	"github.com/neox5/snp/internal/file"
+	"github.com/neox5/snp/internal/stats"
+)
+
+// statsCommand prints language and size statistics without writing a snapshot
+func statsCommand() *cli.Command {
+	flags := []cli.Flag{
+		&cli.IntFlag{
+			Name:  "top",
+			Usage: "Number of largest files to list",
+			Value: stats.DefaultTopN,
+		},
+	}
+
+	return &cli.Command{
+		Name:                      "stats",
+		Usage:                     "Print language and size statistics for a directory",
+		ArgsUsage:                 "[DIRECTORY]",
+		Flags:                     append(flags, filterFlags()...),
+		DisableSliceFlagSeparator: true,
+		Action: func(ctx context.Context, c *cli.Command) error {
+			sourceDir, err := filepath.Abs(sourceDirArg(c, 0))
+			if err != nil {
+				return fmt.Errorf("cannot resolve source directory: %w", err)
+			}
+
+			files, _, _, err := file.Collect(file.CollectOptions{
+				SourceDir:             sourceDir,
+				ExcludePatterns:       c.StringSlice("exclude"),
+				IncludePatterns:       c.StringSlice("include"),
+				ForceTextPatterns:     c.StringSlice("force-text"),
+				ForceBinaryPatterns:   c.StringSlice("force-binary"),
+				ForceEncodingPatterns: c.StringSlice("force-encoding"),
+				Submodules:            c.String("submodules"),
+			})
+			if err != nil {
+				return err
+			}
+
+			for _, line := range stats.Compute(files, c.Int("top")).Lines() {
+				fmt.Println(line)
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+
+	cli "github.com/urfave/cli/v3"
+
+	"github.com/neox5/snp/internal/file"
+	"github.com/neox5/snp/internal/reader"
+)
+
+// verifyCommand checks a snapshot's digest and file hashes
+func verifyCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "verify",
+		Usage:     "Check a snapshot created with --hashes against its digest and the files in a directory",
+		ArgsUsage: "SNAPSHOT [DIRECTORY]",
+		Action: func(ctx context.Context, c *cli.Command) error {
+			if c.NArg() < 1 || c.NArg() > 2 {
+				return fmt.Errorf("verify requires a snapshot file and an optional directory")
+			}
+			snapPath, dir := c.Args().Get(0), sourceDirArg(c, 1)
+
+			snap, err := reader.ReadFile(snapPath)
+			if err != nil {
+				return err
+			}
+
+			recorded, hasDigest := snap.Fields["Digest"]
+			var hashed int
+			for _, f := range snap.Files {
+				if f.Hash != "" {
+					hashed++
+				}
+			}
+			if !hasDigest && hashed == 0 {
+				return fmt.Errorf("%s has no digest or file hashes; create it with --hashes", snapPath)
+			}
+
+			var problems []string
+			if hasDigest && recorded != "sha256:"+snap.Digest() {
+				problems = append(problems, "X  snapshot digest mismatch (modified after it was written)")
+			}
+
+			for _, f := range snap.Files {
+				if f.Hash == "" {
+					continue
+				}
+				hash, err := file.HashFile(filepath.Join(dir, filepath.FromSlash(f.RelPath)))
+				switch {
+				case errors.Is(err, fs.ErrNotExist):
+					problems = append(problems, fmt.Sprintf("D  %s (missing)", f.RelPath))
+				case err != nil:
+					return err
+				case hash != f.Hash:
+					problems = append(problems, fmt.Sprintf("M  %s (hash mismatch)", f.RelPath))
+				}
+			}
+
+			if len(problems) > 0 {
+				fmt.Printf("%s does not match %s:\n", snapPath, dir)
+				for _, p := range problems {
+					fmt.Printf("  %s\n", p)
+				}
+				return cli.Exit("", 1)
+			}
+
+			if hasDigest {
+				fmt.Printf("%s: digest and %d file hashes verified\n", snapPath, hashed)
+			} else {
+				fmt.Printf("%s: %d file hashes verified (no digest recorded)\n", snapPath, hashed)
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	dir := t.TempDir()
+	snapPath := filepath.Join(t.TempDir(), "snap.snp")
+	writeFiles(t, dir, map[string]string{"main.go": "package main\n", "util.go": "package main\n"})
+	if out, code := runSnp(t, dir, "--hashes", "--output", snapPath); code != 0 {
+		t.Fatalf("snp: exit %d\n%s", code, out)
+	}
+
+	if out, code := runSnp(t, dir, "verify", snapPath); code != 0 || !strings.Contains(out, "digest and 2 file hashes verified") {
+		t.Fatalf("verify unchanged: exit %d\n%s", code, out)
+	}
+
+	// Source changes are reported per file
+	writeFiles(t, dir, map[string]string{"main.go": "package main\n\nfunc main() {}\n"})
+	if err := os.Remove(filepath.Join(dir, "util.go")); err != nil {
+		t.Fatal(err)
+	}
+	got, code := runSnp(t, dir, "verify", snapPath)
+	if code != 1 || !strings.Contains(got, "M  main.go (hash mismatch)") || !strings.Contains(got, "D  util.go (missing)") {
+		t.Errorf("verify after source changes: exit %d\n%s", code, got)
+	}
+	if strings.Contains(got, "digest mismatch") {
+		t.Errorf("digest reported for an unmodified snapshot:\n%s", got)
+	}
+
+	// Editing the snapshot breaks its digest
+	b, err := os.ReadFile(snapPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edited := strings.Replace(string(b), "\npackage main\n", "\npackage edited\n", 1)
+	if err := os.WriteFile(snapPath, []byte(edited), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got, code := runSnp(t, dir, "verify", snapPath); code != 1 || !strings.Contains(got, "X  snapshot digest mismatch") {
+		t.Errorf("verify after editing the snapshot: exit %d\n%s", code, got)
+	}
+}
+
+func TestVerify_NoHashes(t *testing.T) {
+	dir := t.TempDir()
+	snapPath := filepath.Join(t.TempDir(), "snap.snp")
+	writeFiles(t, dir, map[string]string{"main.go": "package main\n"})
+	if out, code := runSnp(t, dir, "--output", snapPath); code != 0 {
+		t.Fatalf("snp: exit %d\n%s", code, out)
+	}
+
+	out, code := runSnp(t, dir, "verify", snapPath)
+	if code == 0 || !strings.Contains(out, "has no digest or file hashes") {
+		t.Errorf("verify without hashes: exit %d\n%s", code, out)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	cli "github.com/urfave/cli/v3"
+
+	"github.com/neox5/snp/internal/snapshot"
+	"github.com/neox5/snp/internal/watch"
+)
+
+// watchCommand regenerates the snapshot whenever included files change
+func watchCommand() *cli.Command {
+	flags := []cli.Flag{
+		&cli.DurationFlag{
+			Name:  "interval",
+			Usage: "Time between scans of the source tree",
+			Value: watch.DefaultInterval,
+		},
+		&cli.DurationFlag{
+			Name:  "debounce",
+			Usage: "Wait until no file changed for this long before rebuilding",
+			Value: watch.DefaultDebounce,
+		},
+	}
+
+	return &cli.Command{
+		Name:                      "watch",
+		Usage:                     "Regenerate the snapshot whenever included files change",
+		ArgsUsage:                 "[DIRECTORY]",
+		Flags:                     append(flags, snapshotFlags()...),
+		DisableSliceFlagSeparator: true,
+		Action: func(ctx context.Context, c *cli.Command) error {
+			silent := c.Bool("silent")
+			if c.Duration("interval") <= 0 {
+				return fmt.Errorf("--interval must be positive")
+			}
+
+			cfg, err := configFromFlags(c, sourceDirArg(c, 0))
+			if err != nil {
+				return err
+			}
+			if cfg.DryRun {
+				return fmt.Errorf("--dry-run cannot be combined with watch")
+			}
+			if cfg.OutputPath == snapshot.Stdout {
+				return fmt.Errorf("watch cannot write to stdout")
+			}
+			if cfg.Rev != "" {
+				return fmt.Errorf("--rev snapshots do not change; nothing to watch")
+			}
+			absSourceDir, absOutput, err := snapshot.ValidateAndResolve(cfg)
+			if err != nil {
+				return err
+			}
+
+			ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			// Build errors are reported and the watch goes on, so a
+			// half-finished edit does not end the session
+			rebuild := func(changed []string) []string {
+				if !silent && len(changed) > 0 {
+					fmt.Printf("Changed: %s\n", summarizePaths(changed, 5))
+				}
+
+				start := time.Now()
+				snap, err := snapshot.Build(ctx, cfg, absSourceDir, absOutput)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "snp: %v\n", err)
+					return nil
+				}
+				if !silent {
+					printWarnings(os.Stderr, snap.Warnings)
+				}
+				paths, err := writeSnapshot(snap, absOutput)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "snp: %v\n", err)
+				}
+				if err == nil && !silent {
+					printCreated(os.Stdout, paths, time.Since(start))
+					printCuts(os.Stdout, snap.Cuts)
+				}
+				return paths
+			}
+
+			rebuild(nil)
+			if !silent {
+				fmt.Printf("Watching %s (press Ctrl+C to stop)\n", absSourceDir)
+			}
+
+			return watch.Run(ctx, cfg.CollectOptions(absSourceDir, absOutput), watch.Options{
+				Interval: c.Duration("interval"),
+				Debounce: c.Duration("debounce"),
+			}, rebuild)
+		},
+	}
+}
+
+// summarizePaths joins up to limit paths, e.g. "a.go, b.go and 3 more"
+func summarizePaths(paths []string, limit int) string {
+	if len(paths) <= limit {
+		return strings.Join(paths, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(paths[:limit], ", "), len(paths)-limit)
+}
@@ -0,0 +1,165 @@
+// Package cache persists per-file analysis results between snapshot runs,
+// so unchanged files are not re-detected or re-tokenized.
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Entry holds the analysis results for one file. It is valid while the
+// file's size, modification time and inode are unchanged; Hash additionally
+// ties the token count to the content it was computed from.
+type Entry struct {
+	Size    int64  `json:"size"`
+	ModTime int64  `json:"mtime"` // Unix nanoseconds
+	Inode   uint64 `json:"inode,omitempty"`
+	Hash    string `json:"hash,omitempty"` // SHA-256 of the content (text files)
+	Binary  bool   `json:"binary"`
+	Tokens  int    `json:"tokens,omitempty"`
+	Counted bool   `json:"counted,omitempty"` // Tokens holds an estimate
+}
+
+// file is the on-disk format
+type file struct {
+	Key     string           `json:"key"`
+	Entries map[string]Entry `json:"entries"`
+}
+
+// Cache is the content cache of one source directory. Entries written by
+// a run with another key (snp version and options) are discarded.
+type Cache struct {
+	path    string
+	key     string
+	entries map[string]Entry
+	seen    map[string]bool
+	dirty   bool
+}
+
+// DefaultDir returns the user cache directory of snp, e.g. ~/.cache/snp
+func DefaultDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "snp"), nil
+}
+
+// Path returns the cache file of absSourceDir inside dir
+func Path(dir, absSourceDir string) string {
+	sum := sha256.Sum256([]byte(absSourceDir))
+	return filepath.Join(dir, hex.EncodeToString(sum[:8])+".json")
+}
+
+// Open loads the cache at path. A missing, unreadable or outdated cache
+// starts out empty.
+func Open(path, key string) *Cache {
+	c := &Cache{path: path, key: key, entries: make(map[string]Entry), seen: make(map[string]bool)}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return c
+	}
+	var f file
+	if json.Unmarshal(data, &f) != nil || f.Key != key || f.Entries == nil {
+		c.dirty = true
+		return c
+	}
+	c.entries = f.Entries
+	return c
+}
+
+// Lookup returns the entry of relPath if info still matches it
+func (c *Cache) Lookup(relPath string, info fs.FileInfo) (Entry, bool) {
+	c.seen[relPath] = true
+	e, ok := c.entries[relPath]
+	if !ok || e.Size != info.Size() || e.ModTime != info.ModTime().UnixNano() || e.Inode != inode(info) {
+		return Entry{}, false
+	}
+	return e, true
+}
+
+// Store records the detection results of relPath, dropping token counts
+// computed for other content
+func (c *Cache) Store(relPath string, info fs.FileInfo, binary bool, hash string) {
+	c.seen[relPath] = true
+	e := Entry{
+		Size:    info.Size(),
+		ModTime: info.ModTime().UnixNano(),
+		Inode:   inode(info),
+		Hash:    hash,
+		Binary:  binary,
+	}
+	if old, ok := c.entries[relPath]; ok && old.Hash == hash && old.Counted {
+		e.Tokens, e.Counted = old.Tokens, true
+	}
+	if old := c.entries[relPath]; old != e {
+		c.entries[relPath] = e
+		c.dirty = true
+	}
+}
+
+// Tokens returns the cached token estimate of relPath for content with hash
+func (c *Cache) Tokens(relPath, hash string) (int, bool) {
+	e, ok := c.entries[relPath]
+	if !ok || !e.Counted || e.Hash != hash {
+		return 0, false
+	}
+	return e.Tokens, true
+}
+
+// SetTokens records the token estimate of relPath for content with hash
+func (c *Cache) SetTokens(relPath, hash string, tokens int) {
+	e, ok := c.entries[relPath]
+	if !ok || e.Hash != hash || (e.Counted && e.Tokens == tokens) {
+		return
+	}
+	e.Tokens, e.Counted = tokens, true
+	c.entries[relPath] = e
+	c.dirty = true
+}
+
+// Save writes the cache if it changed, dropping entries of files that were
+// not looked up or stored in this run. The file is replaced atomically.
+func (c *Cache) Save() error {
+	for p := range c.entries {
+		if !c.seen[p] {
+			delete(c.entries, p)
+			c.dirty = true
+		}
+	}
+	if !c.dirty {
+		return nil
+	}
+
+	data, err := json.Marshal(file{Key: c.key, Entries: c.entries})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return fmt.Errorf("cannot create cache directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("cannot write cache: %w", err)
+	}
+	_, err = tmp.Write(data)
+	err = errors.Join(err, tmp.Close())
+	if err == nil {
+		err = os.Rename(tmp.Name(), c.path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("cannot write cache: %w", err)
+	}
+
+	c.dirty = false
+	return nil
+}
@@ -0,0 +1,63 @@
+package cache_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/neox5/snp/internal/cache"
+)
+
+func TestCache_RoundTripAndInvalidation(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(src, []byte("hello\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := cache.Path(dir, "/src")
+	c := cache.Open(path, "v1")
+	if _, ok := c.Lookup("a.txt", info); ok {
+		t.Fatal("empty cache reported a hit")
+	}
+	c.Store("a.txt", info, false, "h1")
+	c.SetTokens("a.txt", "h1", 7)
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	c = cache.Open(path, "v1")
+	e, ok := c.Lookup("a.txt", info)
+	if !ok || e.Binary || e.Hash != "h1" {
+		t.Fatalf("Lookup = %+v, %v", e, ok)
+	}
+	if n, ok := c.Tokens("a.txt", "h1"); !ok || n != 7 {
+		t.Errorf("Tokens = %d, %v; want 7", n, ok)
+	}
+	if _, ok := c.Tokens("a.txt", "h2"); ok {
+		t.Error("Tokens matched another content hash")
+	}
+
+	// A newer modification time invalidates the entry
+	later := info.ModTime().Add(time.Second)
+	if err := os.Chtimes(src, later, later); err != nil {
+		t.Fatal(err)
+	}
+	touched, err := os.Stat(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.Lookup("a.txt", touched); ok {
+		t.Error("Lookup matched a modified file")
+	}
+
+	// Another key (version or options) discards all entries
+	if _, ok := cache.Open(path, "v2").Lookup("a.txt", info); ok {
+		t.Error("Lookup matched an entry written with another key")
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package cache
+
+import "io/fs"
+
+// inode is not available on this platform; entries match by size and
+// modification time only
+func inode(fs.FileInfo) uint64 {
+	return 0
+}
@@ -0,0 +1,17 @@
+//go:build unix
+
+package cache
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// inode returns the inode number of info, so a file replaced by another
+// with the same size and modification time is not mistaken for it
+func inode(info fs.FileInfo) uint64 {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(st.Ino)
+	}
+	return 0
+}
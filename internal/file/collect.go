@@ -1,28 +1,150 @@
 package file
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io/fs"
+	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 
+	"github.com/neox5/snp/internal/cache"
 	"github.com/neox5/snp/internal/ignore"
 )
 
+// CollectOptions configures file discovery and loading
+type CollectOptions struct {
+	SourceDir             string
+	OutputPath            string // Excluded from collection; empty if there is no output file
+	ExcludePatterns       []string
+	IncludePatterns       []string
+	ForceTextPatterns     []string
+	ForceBinaryPatterns   []string
+	ForceEncodingPatterns []string // GLOB=ENCODING overrides; matching files are text unless forced binary
+	Submodules            string   // SubmodulesCollapse (default) or SubmodulesRecurse
+
+	// Cache optionally holds detection results of earlier runs; unchanged
+	// binary files are then not opened at all
+	Cache *cache.Cache
+
+	// Filter optionally restricts collection further; files it rejects
+	// are skipped before their content is loaded
+	Filter func(relPath string) bool
+}
+
+// Modes for directories that hold their own repository (submodules,
+// nested worktrees and clones)
+const (
+	SubmodulesCollapse = "collapse" // skip the directory; list it as a collapsed entry
+	SubmodulesRecurse  = "recurse"  // walk into it, applying its own .gitignore
+)
+
+// ValidateSubmodules checks that mode names a known submodule mode
+func ValidateSubmodules(mode string) error {
+	switch mode {
+	case "", SubmodulesCollapse, SubmodulesRecurse:
+		return nil
+	default:
+		return fmt.Errorf("unknown submodule mode %q (expected %s or %s)",
+			mode, SubmodulesCollapse, SubmodulesRecurse)
+	}
+}
+
+// walkFunc is called for every directory and file below the source
+// directory with the matchers that apply to it
+type walkFunc func(path, relUnix string, d fs.DirEntry, m *ignore.Matchers) error
+
+// nestedMatchers are the ignore rules of a recursed nested repository
+type nestedMatchers struct {
+	prefix   string
+	matchers *ignore.Matchers
+}
+
+// walk visits absSourceDir in lexical order. Included directories holding
+// their own repository are passed to nested; they are skipped unless
+// recursing into submodules, in which case their own .gitignore replaces
+// the outer one.
+func walk(absSourceDir string, opts CollectOptions, fn walkFunc, nested func(relUnix string, m *ignore.Matchers)) error {
+	if err := ValidateSubmodules(opts.Submodules); err != nil {
+		return err
+	}
+
+	rootMatchers, err := ignore.NewMatchers(absSourceDir, opts.ExcludePatterns, opts.IncludePatterns)
+	if err != nil {
+		return err
+	}
+
+	var recursed []nestedMatchers
+	matchersFor := func(relUnix string) *ignore.Matchers {
+		m, longest := rootMatchers, -1
+		for _, n := range recursed {
+			if strings.HasPrefix(relUnix, n.prefix+"/") && len(n.prefix) > longest {
+				m, longest = n.matchers, len(n.prefix)
+			}
+		}
+		return m
+	}
+
+	return filepath.WalkDir(absSourceDir, func(path string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			if errors.Is(walkErr, fs.ErrPermission) {
+				return nil
+			}
+			return walkErr
+		}
+		if path == absSourceDir {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(absSourceDir, path)
+		if err != nil {
+			return nil
+		}
+		relUnix := filepath.ToSlash(relPath)
+		m := matchersFor(relUnix)
+
+		if d.IsDir() && isNestedRepo(path) && m.ShouldInclude(relUnix+"/") {
+			if nested != nil {
+				nested(relUnix, m)
+			}
+			if opts.Submodules != SubmodulesRecurse {
+				return filepath.SkipDir
+			}
+			m, err = m.Nested(relUnix, path)
+			if err != nil {
+				return err
+			}
+			recursed = append(recursed, nestedMatchers{prefix: relUnix, matchers: m})
+		}
+
+		return fn(path, relUnix, d, m)
+	})
+}
+
+// isNestedRepo reports whether dir holds its own repository: a .git
+// directory, or a .git file pointing elsewhere as in submodules
+func isNestedRepo(dir string) bool {
+	_, err := os.Lstat(filepath.Join(dir, ".git"))
+	return err == nil
+}
+
 // Collect discovers, analyzes, and loads files to include in the snapshot
 // Returns: files, textCount, binaryCount, error
-func Collect(sourceDir, outputPath string, excludePatterns, includePatterns, forceTextPatterns, forceBinaryPatterns []string) ([]*File, int, int, error) {
-	absSourceDir, err := filepath.Abs(sourceDir)
+func Collect(opts CollectOptions) ([]*File, int, int, error) {
+	absSourceDir, err := filepath.Abs(opts.SourceDir)
 	if err != nil {
 		return nil, 0, 0, fmt.Errorf("cannot resolve source directory: %w", err)
 	}
 
-	absOutput, err := filepath.Abs(outputPath)
+	absOutput, err := absOutputPath(opts.OutputPath)
 	if err != nil {
-		return nil, 0, 0, fmt.Errorf("cannot resolve output path: %w", err)
+		return nil, 0, 0, err
 	}
 
-	matchers, err := ignore.NewMatchers(absSourceDir, excludePatterns, includePatterns)
+	encodings, err := parseEncodingOverrides(opts.ForceEncodingPatterns)
 	if err != nil {
 		return nil, 0, 0, err
 	}
@@ -30,14 +152,7 @@ func Collect(sourceDir, outputPath string, excludePatterns, includePatterns, for
 	var files []*File
 	var textCount, binaryCount int
 
-	err = filepath.WalkDir(absSourceDir, func(path string, d fs.DirEntry, walkErr error) error {
-		if walkErr != nil {
-			if errors.Is(walkErr, fs.ErrPermission) {
-				return nil
-			}
-			return walkErr
-		}
-
+	err = walk(absSourceDir, opts, func(path, relUnix string, d fs.DirEntry, matchers *ignore.Matchers) error {
 		if d.IsDir() {
 			return nil
 		}
@@ -46,20 +161,26 @@ func Collect(sourceDir, outputPath string, excludePatterns, includePatterns, for
 		if err != nil {
 			return nil
 		}
-		if samePath(absPath, absOutput) {
+		if absOutput != "" && samePath(absPath, absOutput) {
 			return nil
 		}
 
-		relPath, err := filepath.Rel(absSourceDir, path)
-		if err != nil {
+		if !matchers.ShouldInclude(relUnix) {
 			return nil
 		}
-		relUnix := filepath.ToSlash(relPath)
 
-		if !matchers.ShouldInclude(relUnix) {
+		if opts.Filter != nil && !opts.Filter(relUnix) {
 			return nil
 		}
 
+		// Symlinks count only when they point at a regular file
+		if d.Type()&fs.ModeSymlink != 0 {
+			target, err := os.Stat(path)
+			if err != nil || !target.Mode().IsRegular() {
+				return nil
+			}
+		}
+
 		info, err := d.Info()
 		if err != nil {
 			return nil
@@ -68,24 +189,56 @@ func Collect(sourceDir, outputPath string, excludePatterns, includePatterns, for
 
 		var isBinary bool
 
-		// Check force overrides
-		isBinaryOverride, overridden := CheckForceOverride(relUnix, forceTextPatterns, forceBinaryPatterns)
-		if overridden {
+		// Check force overrides, then the cache
+		isBinaryOverride, overridden := CheckForceOverride(relUnix, opts.ForceTextPatterns, opts.ForceBinaryPatterns)
+		encoding := forcedEncoding(relUnix, encodings)
+		if encoding != "" && !(overridden && isBinaryOverride) {
+			isBinaryOverride, overridden = false, true
+		}
+		var cached cache.Entry
+		var hit bool
+		if opts.Cache != nil && !overridden {
+			cached, hit = opts.Cache.Lookup(relUnix, info)
+		}
+		switch {
+		case overridden:
 			isBinary = isBinaryOverride
-		} else {
-			// Detect binary status
+		case hit:
+			isBinary = cached.Binary
+		default:
+			// Detect binary status; unreadable files are skipped like
+			// unreadable directories, as are files deleted meanwhile
 			isBinary, err = DetectBinary(path, fileSize)
-			if err != nil {
+			if errors.Is(err, fs.ErrPermission) || errors.Is(err, fs.ErrNotExist) {
 				return nil
 			}
+			if err != nil {
+				return err
+			}
 		}
 
 		// Create and load file immediately
-		f, err := New(relUnix, path, fileSize, isBinary)
+		f, err := New(relUnix, path, fileSize, isBinary, encoding)
 		if err != nil {
 			return err
 		}
 
+		// Content rewritten without changing size and mtime: detect again
+		if hit && !isBinary && f.Hash != cached.Hash {
+			if isBinary, err = DetectBinary(path, fileSize); err != nil {
+				return err
+			}
+			if isBinary {
+				if f, err = New(relUnix, path, fileSize, true, ""); err != nil {
+					return err
+				}
+			}
+		}
+
+		if opts.Cache != nil && !overridden {
+			opts.Cache.Store(relUnix, info, isBinary, f.Hash)
+		}
+
 		files = append(files, f)
 
 		if isBinary {
@@ -95,16 +248,229 @@ func Collect(sourceDir, outputPath string, excludePatterns, includePatterns, for
 		}
 
 		return nil
+	}, nil)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	return files, textCount, binaryCount, nil
+}
+
+// NestedRepos returns the directories below the source directory that hold
+// their own repository and are not ignored, as forward-slash relative paths
+func NestedRepos(opts CollectOptions) ([]string, error) {
+	absSourceDir, err := filepath.Abs(opts.SourceDir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot resolve source directory: %w", err)
+	}
+
+	var dirs []string
+	err = walk(absSourceDir, opts, func(string, string, fs.DirEntry, *ignore.Matchers) error {
+		return nil
+	}, func(relUnix string, _ *ignore.Matchers) {
+		dirs = append(dirs, relUnix)
 	})
+	if err != nil {
+		return nil, err
+	}
+
+	return dirs, nil
+}
+
+// Stamp identifies a version of a file without reading it
+type Stamp struct {
+	Size    int64
+	ModTime int64 // Unix nanoseconds
+}
+
+// Stamps returns the size and modification time of every file Collect
+// would consider, keyed by forward-slash relative path. Ignored
+// directories are not descended into, so watching them is cheap.
+func Stamps(opts CollectOptions) (map[string]Stamp, error) {
+	absSourceDir, err := filepath.Abs(opts.SourceDir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot resolve source directory: %w", err)
+	}
+	absOutput, err := absOutputPath(opts.OutputPath)
+	if err != nil {
+		return nil, err
+	}
+
+	stamps := make(map[string]Stamp)
+	err = walk(absSourceDir, opts, func(path, relUnix string, d fs.DirEntry, matchers *ignore.Matchers) error {
+		if d.IsDir() {
+			if matchers.SkipsDir(relUnix) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if (absOutput != "" && samePath(path, absOutput)) || !matchers.ShouldInclude(relUnix) {
+			return nil
+		}
+		if opts.Filter != nil && !opts.Filter(relUnix) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		stamps[relUnix] = Stamp{Size: info.Size(), ModTime: info.ModTime().UnixNano()}
+		return nil
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return stamps, nil
+}
+
+// TreeFile is a file that is not read from the filesystem, such as an
+// entry of a git revision
+type TreeFile struct {
+	RelPath string // Forward slashes
+	Size    int64
+}
+
+// CollectTree applies the same filtering, force overrides and binary
+// detection as Collect to files read through load instead of the
+// filesystem. gitignore is the tree root's .gitignore content (nil if absent).
+// Returns: files, textCount, binaryCount, error
+func CollectTree(opts CollectOptions, gitignore []byte, tree []TreeFile, load func(relPath string) ([]byte, error)) ([]*File, int, int, error) {
+	matchers := ignore.NewMatchersFromGitignore(gitignore, opts.ExcludePatterns, opts.IncludePatterns)
+	encodings, err := parseEncodingOverrides(opts.ForceEncodingPatterns)
 	if err != nil {
 		return nil, 0, 0, err
 	}
 
+	var files []*File
+	var textCount, binaryCount int
+
+	// Match the lexical per-directory order of filepath.WalkDir
+	tree = append([]TreeFile(nil), tree...)
+	sort.SliceStable(tree, func(i, j int) bool {
+		return walkLess(tree[i].RelPath, tree[j].RelPath)
+	})
+
+	for _, tf := range tree {
+		if !matchers.ShouldInclude(tf.RelPath) {
+			continue
+		}
+		if opts.Filter != nil && !opts.Filter(tf.RelPath) {
+			continue
+		}
+
+		isBinary, overridden := CheckForceOverride(tf.RelPath, opts.ForceTextPatterns, opts.ForceBinaryPatterns)
+		encoding := forcedEncoding(tf.RelPath, encodings)
+		if encoding != "" && !(overridden && isBinary) {
+			isBinary, overridden = false, true
+		}
+
+		// Forced binary files are not read; ComputeHash reads them on demand
+		var f *File
+		if overridden && isBinary {
+			f = &File{RelPath: tf.RelPath, Size: tf.Size, IsBinary: true}
+			f.read = func() ([]byte, error) { return load(tf.RelPath) }
+			if err := f.LoadContent(); err != nil {
+				return nil, 0, 0, err
+			}
+			files = append(files, f)
+			binaryCount++
+			continue
+		}
+
+		content, err := load(tf.RelPath)
+		if err != nil {
+			return nil, 0, 0, fmt.Errorf("cannot read %q: %w", tf.RelPath, err)
+		}
+		if !overridden {
+			isBinary = DetectBinaryContent(content)
+		}
+
+		if isBinary {
+			sum := sha256.Sum256(content)
+			f = &File{RelPath: tf.RelPath, Size: tf.Size, IsBinary: true, Hash: hex.EncodeToString(sum[:])}
+			err = f.LoadContent()
+		} else {
+			f, err = NewFromContent(tf.RelPath, content, false, encoding)
+		}
+		if err != nil {
+			return nil, 0, 0, err
+		}
+
+		files = append(files, f)
+
+		if isBinary {
+			binaryCount++
+		} else {
+			textCount++
+		}
+	}
+
 	return files, textCount, binaryCount, nil
 }
 
+// walkLess orders slash-separated paths segment by segment, as a
+// lexical directory walk visits them
+func walkLess(a, b string) bool {
+	as, bs := strings.Split(a, "/"), strings.Split(b, "/")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		if as[i] != bs[i] {
+			return as[i] < bs[i]
+		}
+	}
+	return len(as) < len(bs)
+}
+
+// absOutputPath resolves the output path to exclude; empty stays empty
+func absOutputPath(outputPath string) (string, error) {
+	if outputPath == "" {
+		return "", nil
+	}
+	abs, err := filepath.Abs(outputPath)
+	if err != nil {
+		return "", fmt.Errorf("cannot resolve output path: %w", err)
+	}
+	return abs, nil
+}
+
 func samePath(a, b string) bool {
 	ra := filepath.Clean(a)
 	rb := filepath.Clean(b)
 	return ra == rb
 }
+
+// ExcludedDirs returns the top-most directories under the source directory
+// that are ignored and contribute no files, as forward-slash relative paths
+func ExcludedDirs(opts CollectOptions, files []*File) ([]string, error) {
+	absSourceDir, err := filepath.Abs(opts.SourceDir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot resolve source directory: %w", err)
+	}
+
+	var dirs []string
+	err = walk(absSourceDir, opts, func(path, relUnix string, d fs.DirEntry, matchers *ignore.Matchers) error {
+		if !d.IsDir() {
+			return nil
+		}
+
+		if matchers.ShouldInclude(relUnix + "/") {
+			return nil
+		}
+
+		// Ignored directories may still contain files rescued by --include
+		for _, f := range files {
+			if strings.HasPrefix(f.RelPath, relUnix+"/") {
+				return nil
+			}
+		}
+
+		dirs = append(dirs, relUnix)
+		return filepath.SkipDir
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return dirs, nil
+}
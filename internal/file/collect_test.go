@@ -0,0 +1,66 @@
+package file_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/neox5/snp/internal/file"
+)
+
+func TestCollect_Symlinks(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "d"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "d", "a.txt"), []byte("a\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for link, target := range map[string]string{"dir-link": "d", "file-link.txt": "d/a.txt", "dangling": "missing"} {
+		if err := os.Symlink(target, filepath.Join(dir, link)); err != nil {
+			t.Skip("symlinks not supported:", err)
+		}
+	}
+
+	// Links to directories and dangling links are skipped, not errors
+	files, _, _, err := file.Collect(file.CollectOptions{SourceDir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, f := range files {
+		paths = append(paths, f.RelPath)
+	}
+	if want := []string{"d/a.txt", "file-link.txt"}; !slices.Equal(paths, want) {
+		t.Errorf("collected %q, want %q", paths, want)
+	}
+}
+
+func TestCollectTree_ForceBinaryNotLoaded(t *testing.T) {
+	tree := []file.TreeFile{{RelPath: "a.txt", Size: 2}, {RelPath: "big.bin", Size: 4}}
+	var loaded []string
+	load := func(relPath string) ([]byte, error) {
+		loaded = append(loaded, relPath)
+		return []byte(relPath[:1] + "\n"), nil
+	}
+
+	files, text, binary, err := file.CollectTree(file.CollectOptions{ForceBinaryPatterns: []string{"*.bin"}}, nil, tree, load)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != 1 || binary != 1 || !files[1].IsBinary {
+		t.Fatalf("text=%d binary=%d, want one of each", text, binary)
+	}
+	if !slices.Equal(loaded, []string{"a.txt"}) {
+		t.Errorf("loaded %q, want only a.txt", loaded)
+	}
+
+	// Hashing reads the forced binary file on demand
+	if err := files[1].ComputeHash(); err != nil {
+		t.Fatal(err)
+	}
+	if files[1].Hash == "" || !slices.Equal(loaded, []string{"a.txt", "big.bin"}) {
+		t.Errorf("hash %q after loading %q", files[1].Hash, loaded)
+	}
+}
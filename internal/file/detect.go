@@ -30,12 +30,31 @@ func DetectBinary(path string, size int64) (bool, error) {
 		return false, readErr
 	}
 
+	return DetectBinaryContent(buf[:n]), nil
+}
+
+// DetectBinaryContent checks if content is binary, looking at up to the
+// first 512 bytes. Empty content is treated as binary.
+func DetectBinaryContent(content []byte) bool {
+	if len(content) == 0 {
+		return true
+	}
+	buf := content
+	if len(buf) > 512 {
+		buf = buf[:512]
+	}
+
+	// UTF-16 text is full of null bytes
+	if enc := DetectEncoding(buf); enc == EncodingUTF16LE || enc == EncodingUTF16BE {
+		return false
+	}
+
 	// Use http.DetectContentType
-	contentType := http.DetectContentType(buf[:n])
+	contentType := http.DetectContentType(buf)
 
 	// Check if text content type
 	if strings.HasPrefix(contentType, "text/") {
-		return false, nil
+		return false
 	}
 
 	// Known text application types
@@ -45,18 +64,18 @@ func DetectBinary(path string, size int64) (bool, error) {
 		"application/javascript": true,
 	}
 	if textAppTypes[contentType] {
-		return false, nil
+		return false
 	}
 
 	// Fallback: check for null bytes
-	for i := 0; i < n; i++ {
+	for i := 0; i < len(buf); i++ {
 		if buf[i] == 0 {
-			return true, nil
+			return true
 		}
 	}
 
 	// No null bytes found, treat as text
-	return false, nil
+	return false
 }
 
 // CheckForceOverride checks force-text and force-binary patterns
@@ -0,0 +1,197 @@
+package file
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	gitignore "github.com/sabhiram/go-gitignore"
+)
+
+// Text encodings recognized on load. Content is transcoded to UTF-8 for
+// output and byte order marks are stripped.
+const (
+	EncodingUTF8        = "utf-8"
+	EncodingUTF8BOM     = "utf-8-bom" // Detected only; forcing utf-8 also strips a BOM
+	EncodingUTF16LE     = "utf-16le"
+	EncodingUTF16BE     = "utf-16be"
+	EncodingLatin1      = "latin-1"
+	EncodingWindows1252 = "windows-1252"
+)
+
+// Encodings lists the encodings accepted by --force-encoding
+var Encodings = []string{EncodingUTF8, EncodingUTF16LE, EncodingUTF16BE, EncodingLatin1, EncodingWindows1252}
+
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+// DetectEncoding guesses the encoding of text content: a byte order mark
+// decides, then UTF-16 without one, then UTF-8 validity. Content that is
+// mostly valid UTF-8 stays UTF-8; otherwise it is taken as Windows-1252 if
+// it uses its printable 0x80-0x9F range and as Latin-1 if not.
+func DetectEncoding(content []byte) string {
+	switch {
+	case bytes.HasPrefix(content, bomUTF8):
+		return EncodingUTF8BOM
+	case bytes.HasPrefix(content, bomUTF16LE):
+		return EncodingUTF16LE
+	case bytes.HasPrefix(content, bomUTF16BE):
+		return EncodingUTF16BE
+	case looksUTF16(content, binary.LittleEndian):
+		return EncodingUTF16LE
+	case looksUTF16(content, binary.BigEndian):
+		return EncodingUTF16BE
+	case mostlyUTF8(content):
+		return EncodingUTF8
+	}
+
+	for _, b := range content {
+		if b >= 0x80 && b <= 0x9F {
+			return EncodingWindows1252
+		}
+	}
+	return EncodingLatin1
+}
+
+// looksUTF16 reports whether the first 512 bytes of content read as UTF-16
+// text without a byte order mark: no control characters and mostly code
+// units with a zero high byte, as in ASCII-heavy text
+func looksUTF16(content []byte, order binary.ByteOrder) bool {
+	buf := content[:min(len(content), 512)&^1]
+	if len(buf) == 0 {
+		return false
+	}
+
+	narrow := 0
+	for i := 0; i < len(buf); i += 2 {
+		u := order.Uint16(buf[i:])
+		if u < 0x20 && u != '\t' && u != '\n' && u != '\r' || u == 0xFFFE || u == 0xFFFF {
+			return false
+		}
+		if u < 0x100 {
+			narrow++
+		}
+	}
+	return narrow*2 >= len(buf)/2
+}
+
+// mostlyUTF8 reports whether content is UTF-8 apart from a few stray
+// bytes: valid multi-byte sequences outnumber invalid bytes. Legacy 8-bit
+// text rarely forms valid sequences by accident.
+func mostlyUTF8(content []byte) bool {
+	if utf8.Valid(content) {
+		return true
+	}
+	multi, invalid := 0, 0
+	for len(content) > 0 {
+		r, size := utf8.DecodeRune(content)
+		switch {
+		case r == utf8.RuneError && size == 1:
+			invalid++
+		case size > 1:
+			multi++
+		}
+		content = content[size:]
+	}
+	return multi > invalid
+}
+
+// decodeText transcodes content in encoding to UTF-8 and strips a byte
+// order mark. Invalid UTF-8 is replaced by U+FFFD. Returns the encoding to
+// record: "" for plain UTF-8.
+func decodeText(content []byte, encoding string) ([]byte, string) {
+	switch encoding {
+	case EncodingUTF8, EncodingUTF8BOM:
+		recorded := ""
+		if bytes.HasPrefix(content, bomUTF8) {
+			content, recorded = content[len(bomUTF8):], EncodingUTF8BOM
+		}
+		if !utf8.Valid(content) {
+			content = bytes.ToValidUTF8(content, []byte("\uFFFD"))
+		}
+		return content, recorded
+	case EncodingUTF16LE:
+		return decodeUTF16(bytes.TrimPrefix(content, bomUTF16LE), binary.LittleEndian), encoding
+	case EncodingUTF16BE:
+		return decodeUTF16(bytes.TrimPrefix(content, bomUTF16BE), binary.BigEndian), encoding
+	case EncodingLatin1, EncodingWindows1252:
+		out := make([]byte, 0, len(content))
+		for _, b := range content {
+			r := rune(b)
+			if encoding == EncodingWindows1252 && b >= 0x80 && b <= 0x9F && windows1252[b-0x80] != 0 {
+				r = windows1252[b-0x80]
+			}
+			out = utf8.AppendRune(out, r)
+		}
+		return out, encoding
+	}
+	return content, ""
+}
+
+// decodeUTF16 decodes UTF-16 code units; a trailing odd byte is dropped
+func decodeUTF16(content []byte, order binary.ByteOrder) []byte {
+	units := make([]uint16, len(content)/2)
+	for i := range units {
+		units[i] = order.Uint16(content[2*i:])
+	}
+	out := make([]byte, 0, len(content))
+	for _, r := range utf16.Decode(units) {
+		out = utf8.AppendRune(out, r)
+	}
+	return out
+}
+
+// windows1252 maps bytes 0x80-0x9F; zero entries are undefined and decode
+// as in Latin-1
+var windows1252 = [32]rune{
+	0x20AC, 0, 0x201A, 0x0192, 0x201E, 0x2026, 0x2020, 0x2021,
+	0x02C6, 0x2030, 0x0160, 0x2039, 0x0152, 0, 0x017D, 0,
+	0, 0x2018, 0x2019, 0x201C, 0x201D, 0x2022, 0x2013, 0x2014,
+	0x02DC, 0x2122, 0x0161, 0x203A, 0x0153, 0, 0x017E, 0x0178,
+}
+
+// encodingOverride forces the encoding of files matching a glob pattern
+type encodingOverride struct {
+	matcher  *gitignore.GitIgnore
+	encoding string
+}
+
+// parseEncodingOverrides parses GLOB=ENCODING patterns
+func parseEncodingOverrides(patterns []string) ([]encodingOverride, error) {
+	overrides := make([]encodingOverride, 0, len(patterns))
+	for _, p := range patterns {
+		i := strings.LastIndex(p, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid force-encoding pattern %q: expected GLOB=ENCODING", p)
+		}
+		encoding := strings.ToLower(p[i+1:])
+		if !slices.Contains(Encodings, encoding) {
+			return nil, fmt.Errorf("invalid force-encoding pattern %q: encoding must be one of %s", p, strings.Join(Encodings, ", "))
+		}
+		overrides = append(overrides, encodingOverride{
+			matcher:  gitignore.CompileIgnoreLines(p[:i]),
+			encoding: encoding,
+		})
+	}
+	return overrides, nil
+}
+
+// forcedEncoding returns the encoding of the first override matching
+// relPath, or ""
+func forcedEncoding(relPath string, overrides []encodingOverride) string {
+	relUnix := filepath.ToSlash(relPath)
+	for _, o := range overrides {
+		if o.matcher.MatchesPath(relUnix) {
+			return o.encoding
+		}
+	}
+	return ""
+}
@@ -0,0 +1,68 @@
+package file_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/neox5/snp/internal/file"
+)
+
+func TestCollect_Encodings(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, content []byte) {
+		if err := os.WriteFile(filepath.Join(dir, name), content, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// "héllo\nwörld\n" in several encodings
+	write("bom.txt", []byte("\xEF\xBB\xBFh\xC3\xA9llo\nw\xC3\xB6rld\n"))
+	write("le.txt", []byte("\xFF\xFEh\x00\xE9\x00l\x00l\x00o\x00\n\x00w\x00\xF6\x00r\x00l\x00d\x00\n\x00"))
+	write("be.txt", []byte("\x00h\x00\xE9\x00l\x00l\x00o\x00\n\x00w\x00\xF6\x00r\x00l\x00d\x00\n"))
+	write("latin1.txt", []byte("h\xE9llo\nw\xF6rld\n"))
+	write("forced.txt", []byte("h\xE9llo\nw\xF6rld\n"))
+	write("cp1252.txt", []byte("\x93h\xE9llo\x94\n"))
+
+	// UTF-8 with a stray byte stays UTF-8; Latin-1 that happens to contain
+	// one valid UTF-8 sequence does not
+	write("mixed.txt", []byte("caf\xC3\xA9 \x93ok\nna\xC3\xAFve\n"))
+	write("latin1-mixed.txt", []byte("h\xE9llo w\xF6rld \xC3\xA9\n"))
+
+	files, _, _, err := file.Collect(file.CollectOptions{
+		SourceDir:             dir,
+		ForceEncodingPatterns: []string{"forced.txt=windows-1252"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]struct {
+		encoding string
+		lines    []string
+	}{
+		"bom.txt":          {file.EncodingUTF8BOM, []string{"héllo", "wörld"}},
+		"le.txt":           {file.EncodingUTF16LE, []string{"héllo", "wörld"}},
+		"be.txt":           {file.EncodingUTF16BE, []string{"héllo", "wörld"}},
+		"latin1.txt":       {file.EncodingLatin1, []string{"héllo", "wörld"}},
+		"forced.txt":       {file.EncodingWindows1252, []string{"héllo", "wörld"}},
+		"cp1252.txt":       {file.EncodingWindows1252, []string{"“héllo”"}},
+		"mixed.txt":        {"", []string{"café \uFFFDok", "naïve"}},
+		"latin1-mixed.txt": {file.EncodingLatin1, []string{"héllo wörld Ã©"}},
+	}
+	for _, f := range files {
+		w := want[f.RelPath]
+		if f.IsBinary || f.Encoding != w.encoding || !slices.Equal(f.Lines, w.lines) {
+			t.Errorf("%s: binary=%v encoding=%q lines=%q; want %q %q", f.RelPath, f.IsBinary, f.Encoding, f.Lines, w.encoding, w.lines)
+		}
+	}
+	if len(files) != len(want) {
+		t.Errorf("collected %d files, want %d", len(files), len(want))
+	}
+
+	_, _, _, err = file.Collect(file.CollectOptions{SourceDir: dir, ForceEncodingPatterns: []string{"*.txt=ebcdic"}})
+	if err == nil {
+		t.Error("unknown encoding accepted")
+	}
+}
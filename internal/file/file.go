@@ -2,27 +2,68 @@ package file
 
 import (
 	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"os"
+
+	"github.com/neox5/snp/internal/unicheck"
+	"github.com/neox5/snp/internal/writer"
 )
 
 // File represents a file in the snapshot
 type File struct {
-	RelPath   string
-	FullPath  string
-	Size      int64
-	IsBinary  bool
-	Lines     []string
-	StartLine int
+	RelPath    string
+	FullPath   string
+	Size       int64
+	IsBinary   bool
+	Hash       string // SHA-256 of the content (text files)
+	Encoding   string // Original encoding of text not in plain UTF-8 (e.g. utf-16le)
+	Lines      []string
+	StartLine  int
+	EndLine    int
+	Part       int // Part containing StartLine (0 when not split)
+	EndPart    int // Part containing EndLine (0 when not split)
+	Tokens     int
+	Secrets    int      // Number of secrets replaced by placeholders
+	Redactions int      // Number of replacements made by redaction rules
+	GitStatus  string   // Working tree status mark (modified, added, ...)
+	ChangeMark string   // Why the file is in a --changed-since snapshot
+	Git        *GitInfo // Per-file git metadata (optional)
+
+	// Annotations are per-line notes (e.g. blame) written before or after
+	// Lines; a missing entry leaves the line unannotated
+	Annotations      []string
+	AnnotationSuffix bool
+
+	// Unicode lists bidi controls, invisible characters and homoglyphs
+	// found when loading the content
+	Unicode []unicheck.Finding
+
+	// read reads content that is not on disk, such as a blob of a git
+	// revision, for ComputeHash
+	read func() ([]byte, error)
 }
 
-// New creates a new File and loads its content
-func New(relPath, fullPath string, size int64, isBinary bool) (*File, error) {
+// GitInfo holds per-file git metadata shown in the file index
+type GitInfo struct {
+	Commit  string // Abbreviated hash of the last commit touching the file
+	Date    string // Date of that commit (YYYY-MM-DD)
+	Author  string // Author of that commit
+	Commits int    // Number of commits touching the file
+}
+
+// New creates a new File and loads its content. A non-empty encoding
+// overrides encoding detection.
+func New(relPath, fullPath string, size int64, isBinary bool, encoding string) (*File, error) {
 	f := &File{
 		RelPath:  relPath,
 		FullPath: fullPath,
 		Size:     size,
 		IsBinary: isBinary,
+		Encoding: encoding,
 	}
 
 	// Load content
@@ -33,36 +74,135 @@ func New(relPath, fullPath string, size int64, isBinary bool) (*File, error) {
 	return f, nil
 }
 
+// NewFromContent creates a new File from content that is not read from the
+// filesystem, such as a blob from a git revision
+func NewFromContent(relPath string, content []byte, isBinary bool, encoding string) (*File, error) {
+	f := &File{
+		RelPath:  relPath,
+		Size:     int64(len(content)),
+		IsBinary: isBinary,
+		Encoding: encoding,
+	}
+
+	if isBinary {
+		f.Lines = []string{binaryPlaceholder(f.Size)}
+		return f, nil
+	}
+
+	if err := f.setContent(content); err != nil {
+		return nil, fmt.Errorf("failed to load content: %w", err)
+	}
+	return f, nil
+}
+
 // LoadContent loads the file's content into Lines
 func (f *File) LoadContent() error {
 	if f.IsBinary {
 		// Binary: create placeholder line
-		sizeStr := FormatSize(f.Size)
-		f.Lines = []string{
-			fmt.Sprintf("[Binary file - %s - content omitted]", sizeStr),
-		}
+		f.Lines = []string{binaryPlaceholder(f.Size)}
 		return nil
 	}
 
 	// Text: load actual lines
-	lines, err := loadFileLines(f.FullPath)
+	content, err := os.ReadFile(f.FullPath)
+	if err == nil {
+		err = f.setContent(content)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to load content: %w", err)
 	}
-	f.Lines = lines
 	return nil
 }
 
-// loadFileLines reads a file into a slice of lines
-func loadFileLines(path string) ([]string, error) {
-	file, err := os.Open(path)
+// ComputeHash sets Hash for files whose content was not loaded, such as
+// binary files, by reading the file
+func (f *File) ComputeHash() error {
+	if f.Hash != "" {
+		return nil
+	}
+	if f.FullPath == "" {
+		if f.read == nil {
+			return nil
+		}
+		content, err := f.read()
+		if err != nil {
+			return fmt.Errorf("cannot hash %q: %w", f.RelPath, err)
+		}
+		sum := sha256.Sum256(content)
+		f.Hash = hex.EncodeToString(sum[:])
+		return nil
+	}
+	hash, err := HashFile(f.FullPath)
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("cannot hash %q: %w", f.RelPath, err)
 	}
-	defer file.Close()
+	f.Hash = hash
+	return nil
+}
+
+// HashFile returns the hex SHA-256 of the file at path
+func HashFile(path string) (string, error) {
+	r, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// setContent transcodes text content to UTF-8 unless it already is, splits
+// it into Lines, records its hash and checks it for suspicious characters.
+// The encoding is detected unless Encoding is already set.
+func (f *File) setContent(content []byte) error {
+	encoding := f.Encoding
+	if encoding == "" {
+		encoding = DetectEncoding(content)
+	}
+	text, encoding := decodeText(content, encoding)
+
+	lines, err := readLines(bytes.NewReader(text))
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(content)
+	f.Encoding = encoding
+	f.Lines = lines
+	f.Hash = hex.EncodeToString(sum[:])
+	f.Unicode = unicheck.Check(lines)
+	return nil
+}
+
+// OutputLines returns Lines with annotations applied, as they are written
+// to the snapshot
+func (f *File) OutputLines() []string {
+	if len(f.Annotations) == 0 {
+		return f.Lines
+	}
+
+	lines := make([]string, len(f.Lines))
+	for i, line := range f.Lines {
+		if i < len(f.Annotations) {
+			line = writer.Annotate(line, f.Annotations[i], f.AnnotationSuffix)
+		}
+		lines[i] = line
+	}
+	return lines
+}
+
+// binaryPlaceholder returns the line shown instead of binary content
+func binaryPlaceholder(size int64) string {
+	return fmt.Sprintf("[Binary file - %s - content omitted]", FormatSize(size))
+}
 
+// readLines reads r into a slice of lines
+func readLines(r io.Reader) ([]string, error) {
 	var lines []string
-	scanner := bufio.NewScanner(file)
+	scanner := bufio.NewScanner(r)
 	for scanner.Scan() {
 		lines = append(lines, scanner.Text())
 	}
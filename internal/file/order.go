@@ -0,0 +1,105 @@
+package file
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	gitignore "github.com/sabhiram/go-gitignore"
+)
+
+// Sort strategies for ordering files within a priority group
+const (
+	SortPath    = "path"    // lexical walk order (default)
+	SortSize    = "size"    // smallest files first
+	SortRecency = "recency" // most recently committed or modified first
+	SortDepth   = "depth"   // shallowest files first
+)
+
+// SortOptions controls the order files appear in the index and body.
+// The order doubles as priority: truncation and budget logic cut files
+// from the end.
+type SortOptions struct {
+	PriorityPatterns []string             // Globs; earlier patterns rank higher
+	By               string               // One of the Sort* strategies
+	CommitTimes      map[string]time.Time // Last commit time per path (SortRecency)
+}
+
+// ValidateSort checks that by names a known sort strategy
+func ValidateSort(by string) error {
+	switch by {
+	case "", SortPath, SortSize, SortRecency, SortDepth:
+		return nil
+	default:
+		return fmt.Errorf("unknown sort strategy %q (expected %s, %s, %s or %s)",
+			by, SortPath, SortSize, SortRecency, SortDepth)
+	}
+}
+
+// Sort orders files by priority group first, then by the sort strategy.
+// The sort is stable, so collection (lexical walk) order breaks ties.
+func Sort(files []*File, opts SortOptions) {
+	// Priority group: index of the first matching pattern
+	matchers := make([]*gitignore.GitIgnore, len(opts.PriorityPatterns))
+	for i, p := range opts.PriorityPatterns {
+		matchers[i] = gitignore.CompileIgnoreLines(p)
+	}
+	group := make(map[*File]int, len(files))
+	for _, f := range files {
+		group[f] = len(matchers)
+		for i, m := range matchers {
+			if m.MatchesPath(f.RelPath) {
+				group[f] = i
+				break
+			}
+		}
+	}
+
+	var recency map[*File]time.Time
+	if opts.By == SortRecency {
+		recency = make(map[*File]time.Time, len(files))
+		for _, f := range files {
+			recency[f] = lastChange(f, opts.CommitTimes)
+		}
+	}
+
+	sort.SliceStable(files, func(i, j int) bool {
+		a, b := files[i], files[j]
+		if group[a] != group[b] {
+			return group[a] < group[b]
+		}
+
+		switch opts.By {
+		case SortSize:
+			if a.Size != b.Size {
+				return a.Size < b.Size
+			}
+		case SortRecency:
+			if !recency[a].Equal(recency[b]) {
+				return recency[a].After(recency[b])
+			}
+		case SortDepth:
+			da, db := strings.Count(a.RelPath, "/"), strings.Count(b.RelPath, "/")
+			if da != db {
+				return da < db
+			}
+		}
+
+		return false
+	})
+}
+
+// lastChange returns the last commit time of f, falling back to the file's
+// modification time for files without history (untracked or no repository)
+func lastChange(f *File, commitTimes map[string]time.Time) time.Time {
+	if t, ok := commitTimes[filepath.ToSlash(f.RelPath)]; ok {
+		return t
+	}
+	if info, err := os.Stat(f.FullPath); err == nil {
+		return info.ModTime()
+	}
+	return time.Time{}
+}
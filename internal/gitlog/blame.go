@@ -0,0 +1,86 @@
+package gitlog
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// BlameLine identifies the commit that last touched a line
+type BlameLine struct {
+	Commit string // Abbreviated hash, empty for uncommitted lines
+	Author string
+}
+
+// uncommittedHash is the hash git blame reports for uncommitted lines
+const uncommittedHash = "0000000000000000000000000000000000000000"
+
+// Blame returns per-line blame information for relPath (relative to root)
+// at rev, or at the working tree when rev is empty. One git invocation
+// covers the whole file.
+func Blame(ctx context.Context, root, rev, relPath string) ([]BlameLine, error) {
+	if !HasGitBinary() {
+		return nil, fmt.Errorf("git blame: %w", ErrNoGit)
+	}
+
+	args := []string{"-C", root, "blame", "--porcelain"}
+	if rev != "" {
+		args = append(args, rev)
+	}
+	args = append(args, "--", relPath)
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("git blame %s: %s", relPath, strings.TrimSpace(stderr.String()))
+	}
+
+	return parsePorcelainBlame(&stdout)
+}
+
+// parsePorcelainBlame parses "git blame --porcelain" output. Commit
+// headers (author etc.) appear only the first time a commit is seen.
+func parsePorcelainBlame(r *bytes.Buffer) ([]BlameLine, error) {
+	authors := make(map[string]string)
+
+	var lines []BlameLine
+	var commit string
+	expectHeader := true
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		switch {
+		case expectHeader:
+			// <sha> <orig-line> <final-line> [<group-lines>]
+			fields := strings.Fields(line)
+			if len(fields) < 3 {
+				return nil, fmt.Errorf("unexpected blame header %q", line)
+			}
+			commit = fields[0]
+			expectHeader = false
+
+		case strings.HasPrefix(line, "\t"):
+			// Content line ends the entry
+			bl := BlameLine{Author: authors[commit]}
+			if commit != uncommittedHash {
+				bl.Commit = commit[:8]
+			}
+			lines = append(lines, bl)
+			expectHeader = true
+
+		case strings.HasPrefix(line, "author "):
+			authors[commit] = strings.TrimPrefix(line, "author ")
+		}
+	}
+
+	return lines, scanner.Err()
+}
@@ -0,0 +1,142 @@
+package gitlog
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Changes holds the files touched between a merge base and the working tree
+type Changes struct {
+	Ref   string              // Ref the changes are relative to
+	Base  string              // Merge base of Ref and HEAD
+	Paths map[string]bool     // Changed paths relative to root (including untracked)
+	Diffs map[string][]string // Unified diff per path relative to root
+	Order []string            // Paths in diff order
+
+	dirs map[string]bool // Directories containing changed paths
+}
+
+// CollectChanges finds files changed between the merge base of ref and HEAD
+// and the working tree, including untracked files
+func CollectChanges(ctx context.Context, root, ref string) (*Changes, error) {
+	if ref == "" || strings.HasPrefix(ref, "-") {
+		return nil, fmt.Errorf("invalid ref %q", ref)
+	}
+
+	baseLines, err := runLines(ctx, root, "merge-base", ref, "HEAD")
+	if err != nil {
+		return nil, fmt.Errorf("cannot find merge base of %q and HEAD: %w", ref, err)
+	}
+	if len(baseLines) == 0 {
+		return nil, fmt.Errorf("no merge base for %q and HEAD", ref)
+	}
+
+	ch := &Changes{
+		Ref:   ref,
+		Base:  baseLines[0],
+		Paths: make(map[string]bool),
+		Diffs: make(map[string][]string),
+	}
+
+	names, err := runLines(ctx, root, "diff", "--name-only", "--relative", ch.Base)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		ch.Paths[name] = true
+	}
+
+	untracked, err := runLines(ctx, root, "ls-files", "--others", "--exclude-standard")
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range untracked {
+		ch.Paths[name] = true
+	}
+
+	ch.dirs = make(map[string]bool)
+	for p := range ch.Paths {
+		ch.dirs[path.Dir(p)] = true
+	}
+
+	diff, err := runLines(ctx, root, "diff", "--relative", ch.Base)
+	if err != nil {
+		return nil, err
+	}
+	ch.splitDiff(diff)
+
+	return ch, nil
+}
+
+// splitDiff splits a combined unified diff into per-file diffs
+func (ch *Changes) splitDiff(lines []string) {
+	current := ""
+	for _, line := range lines {
+		if strings.HasPrefix(line, "diff --git ") {
+			current = diffPath(line)
+			ch.Order = append(ch.Order, current)
+		}
+		if current != "" {
+			ch.Diffs[current] = append(ch.Diffs[current], line)
+		}
+	}
+}
+
+// Restrict drops the diffs of paths keep rejects, such as files excluded
+// from the snapshot. The changed paths still decide what is collected.
+func (ch *Changes) Restrict(keep func(relPath string) bool) {
+	var order []string
+	for _, p := range ch.Order {
+		if keep(p) {
+			order = append(order, p)
+		} else {
+			delete(ch.Diffs, p)
+		}
+	}
+	ch.Order = order
+}
+
+// filterDiff keeps the per-file parts of a unified diff whose path keep
+// accepts
+func filterDiff(lines []string, keep func(relPath string) bool) []string {
+	var kept []string
+	include := false
+	for _, line := range lines {
+		if strings.HasPrefix(line, "diff --git ") {
+			include = keep(diffPath(line))
+		}
+		if include {
+			kept = append(kept, line)
+		}
+	}
+	return kept
+}
+
+// diffPath extracts the new path from a "diff --git a/x b/x" header
+func diffPath(header string) string {
+	rest := strings.TrimPrefix(header, "diff --git ")
+	if i := strings.LastIndex(rest, " b/"); i >= 0 {
+		return rest[i+len(" b/"):]
+	}
+	return rest
+}
+
+// Touches reports whether relPath is changed, or with neighbors set,
+// whether it shares a directory with a changed path
+func (ch *Changes) Touches(relPath string, neighbors bool) bool {
+	if ch.Paths[relPath] {
+		return true
+	}
+	return neighbors && ch.dirs[path.Dir(relPath)]
+}
+
+// DiffLines returns the per-file diffs concatenated in diff order
+func (ch *Changes) DiffLines() []string {
+	var lines []string
+	for _, p := range ch.Order {
+		lines = append(lines, ch.Diffs[p]...)
+	}
+	return lines
+}
@@ -0,0 +1,10 @@
+package gitlog
+
+import "testing"
+
+// SetGitBinary overrides the git binary lookup until the test ends
+func SetGitBinary(t *testing.T, available bool) {
+	prev := HasGitBinary()
+	gitBinary = available
+	t.Cleanup(func() { gitBinary = prev })
+}
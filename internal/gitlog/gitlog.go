@@ -4,15 +4,34 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
+
+	"github.com/neox5/snp/internal/gitrepo"
 )
 
-// HasRepo reports whether a .git directory exists under root
+// HasRepo reports whether root is the top level of a git working tree.
+// ".git" may be a "gitdir:" file as used by linked worktrees and
+// submodules. Repositories in parent directories, such as a dotfiles
+// repository in $HOME, are deliberately not considered.
 func HasRepo(root string) bool {
-	info, err := os.Stat(filepath.Join(root, ".git"))
-	return err == nil && info.IsDir()
+	dotGit := filepath.Join(root, ".git")
+	info, err := os.Stat(dotGit)
+	if err != nil {
+		return false
+	}
+
+	gitDir := dotGit
+	if !info.IsDir() {
+		if gitDir, err = gitrepo.ReadGitFile(dotGit); err != nil {
+			return false
+		}
+	}
+	_, err = gitrepo.Open(root, gitDir)
+	return err == nil
 }
 
 // GitLogData represents collected git log information
@@ -20,15 +39,85 @@ type GitLogData struct {
 	Lines []string
 }
 
-// Collect retrieves git log output as lines
-func Collect(ctx context.Context, root string) (*GitLogData, error) {
-	var buf bytes.Buffer
-	cmd := exec.CommandContext(ctx, "git", "-C", root, "log", "--all", "--decorate", "--oneline", "--graph")
+// Options controls which commits the git log section shows and how.
+// The zero value is the compact "git adog" view of all refs.
+type Options struct {
+	MaxCount int    // Maximum number of commits (0 = unlimited)
+	Since    string // Only commits newer than this date (git --since syntax)
+	Range    string // Revision range, e.g. "v1.0..HEAD" (overrides branch selection)
+	OnlyHEAD bool   // Only the current branch instead of --all
+	Full     bool   // Full commit messages with author and date
+}
+
+// Validate checks options that are passed to git verbatim
+func (o Options) Validate() error {
+	if strings.HasPrefix(o.Range, "-") {
+		return fmt.Errorf("invalid git log range %q", o.Range)
+	}
+	if o.MaxCount < 0 {
+		return fmt.Errorf("invalid git log max count %d", o.MaxCount)
+	}
+	return nil
+}
+
+// IsDefault reports whether the options select the default "git adog" view
+func (o Options) IsDefault() bool {
+	return o == Options{}
+}
+
+// Args returns the git log arguments for the options
+func (o Options) Args() []string {
+	args := []string{"log", "--decorate"}
+
+	if o.Full {
+		args = append(args, "--graph", "--pretty=medium", "--date=iso")
+	} else {
+		args = append(args, "--oneline", "--graph")
+	}
+
+	if o.MaxCount > 0 {
+		args = append(args, fmt.Sprintf("--max-count=%d", o.MaxCount))
+	}
+	if o.Since != "" {
+		args = append(args, "--since="+o.Since)
+	}
+
+	switch {
+	case o.Range != "":
+		args = append(args, o.Range)
+	case !o.OnlyHEAD:
+		args = append(args, "--all")
+	}
+
+	return args
+}
+
+// Describe returns a short description of the log command for headers
+func (o Options) Describe() string {
+	if o.IsDefault() {
+		return "git adog"
+	}
+	return "git " + strings.Join(o.Args(), " ")
+}
+
+// Collect retrieves git log output as lines, reading the repository
+// natively when the git binary is not installed
+func Collect(ctx context.Context, root string, opts Options) (*GitLogData, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	if !HasGitBinary() {
+		return collectNative(root, opts)
+	}
+
+	var buf, stderr bytes.Buffer
+	args := append([]string{"-C", root}, opts.Args()...)
+	cmd := exec.CommandContext(ctx, "git", args...)
 	cmd.Stdout = &buf
-	cmd.Stderr = os.Stderr
+	cmd.Stderr = &stderr
 
 	if err := cmd.Run(); err != nil {
-		return nil, err
+		return nil, commandError("log", err, &stderr)
 	}
 
 	var lines []string
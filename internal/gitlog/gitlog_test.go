@@ -0,0 +1,274 @@
+package gitlog_test
+
+import (
+	"context"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/neox5/snp/internal/gitlog"
+)
+
+// git runs the git binary in dir and returns trimmed output
+func git(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=Jane Doe", "GIT_AUTHOR_EMAIL=jane@example.com",
+		"GIT_COMMITTER_NAME=Jane Doe", "GIT_COMMITTER_EMAIL=jane@example.com",
+	)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// writeFile writes content to name below dir
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	path := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newRepo creates a repository with two commits, the first tagged v1
+func newRepo(t *testing.T) string {
+	t.Helper()
+	if !gitlog.HasGitBinary() {
+		t.Skip("git binary not available")
+	}
+
+	dir := t.TempDir()
+	git(t, dir, "init", "-q", "-b", "main")
+	writeFile(t, dir, "a.txt", "one\ntwo\n")
+	writeFile(t, dir, "sub/b.txt", "bee\n")
+	git(t, dir, "add", "-A")
+	git(t, dir, "commit", "-q", "-m", "first")
+	git(t, dir, "tag", "v1")
+
+	writeFile(t, dir, "a.txt", "one\ntwo\nthree\n")
+	git(t, dir, "commit", "-q", "-am", "second")
+	return dir
+}
+
+func TestCollect(t *testing.T) {
+	dir := newRepo(t)
+
+	tests := []struct {
+		name string
+		opts gitlog.Options
+		want []string
+	}{
+		{"default", gitlog.Options{}, []string{"second", "first"}},
+		{"max count", gitlog.Options{MaxCount: 1}, []string{"second"}},
+		{"range", gitlog.Options{Range: "v1..HEAD"}, []string{"second"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := gitlog.Collect(context.Background(), dir, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(data.Lines) != len(tt.want) {
+				t.Fatalf("lines = %q, want %d commits", data.Lines, len(tt.want))
+			}
+			for i, subject := range tt.want {
+				if !strings.HasPrefix(data.Lines[i], "* ") || !strings.HasSuffix(data.Lines[i], " "+subject) {
+					t.Errorf("line %d = %q, want commit %q", i, data.Lines[i], subject)
+				}
+			}
+		})
+	}
+
+	if _, err := gitlog.Collect(context.Background(), dir, gitlog.Options{Range: "--output=x"}); err == nil {
+		t.Error("range starting with a dash accepted")
+	}
+}
+
+func TestCollectWorkTree(t *testing.T) {
+	dir := newRepo(t)
+	writeFile(t, dir, "a.txt", "one\ntwo\nthree\nfour\n")
+	writeFile(t, dir, "sub/b.txt", "bee\nstaged\n")
+	writeFile(t, dir, "sub/new.txt", "new\n")
+	writeFile(t, dir, "sub/added.txt", "added\n")
+	git(t, dir, "add", "sub/b.txt", "sub/added.txt")
+	git(t, dir, "mv", "a.txt", "renamed.txt")
+
+	// Paths are relative to the subdirectory snapshotted
+	wt, err := gitlog.CollectWorkTree(context.Background(), filepath.Join(dir, "sub"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantStatus := map[string]string{
+		"added.txt": gitlog.StatusAdded,
+		"b.txt":     gitlog.StatusModified,
+		"new.txt":   gitlog.StatusUntracked,
+	}
+	if !maps.Equal(wt.FileStatus, wantStatus) {
+		t.Errorf("FileStatus = %v, want %v", wt.FileStatus, wantStatus)
+	}
+	if !slices.Contains(wt.StagedDiff, "+staged") || !slices.Contains(wt.StagedDiff, "diff --git a/b.txt b/b.txt") {
+		t.Errorf("StagedDiff = %q", wt.StagedDiff)
+	}
+	if len(wt.StatusLines) != 3 {
+		t.Errorf("StatusLines = %q, want 3 lines", wt.StatusLines)
+	}
+
+	// Renames report the new path
+	wt, err = gitlog.CollectWorkTree(context.Background(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := wt.FileStatus["renamed.txt"]; got != gitlog.StatusRenamed {
+		t.Errorf("renamed.txt status = %q, want %q", got, gitlog.StatusRenamed)
+	}
+	if _, ok := wt.FileStatus["a.txt"]; ok {
+		t.Error("status reported for the old path of a rename")
+	}
+}
+
+func TestWorkTree_Restrict(t *testing.T) {
+	dir := newRepo(t)
+	writeFile(t, dir, "a.txt", "changed\n")
+	writeFile(t, dir, "sub/b.txt", "changed\n")
+	writeFile(t, dir, "sub/new file.txt", "new\n")
+
+	wt, err := gitlog.CollectWorkTree(context.Background(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wt.Restrict(func(relPath string) bool { return strings.HasPrefix(relPath, "sub/") })
+
+	if len(wt.StatusLines) != 2 {
+		t.Errorf("StatusLines = %q, want the two sub/ lines", wt.StatusLines)
+	}
+	for _, line := range wt.UnstagedDiff {
+		if strings.Contains(line, "a.txt") {
+			t.Errorf("diff of a.txt kept: %q", line)
+		}
+	}
+	if _, ok := wt.FileStatus["sub/new file.txt"]; !ok {
+		t.Error("quoted status path dropped")
+	}
+	if _, ok := wt.FileStatus["a.txt"]; ok {
+		t.Error("status of a.txt kept")
+	}
+}
+
+func TestBlame(t *testing.T) {
+	dir := newRepo(t)
+	writeFile(t, dir, "a.txt", "one\ntwo\nthree\nuncommitted\n")
+	first := git(t, dir, "rev-parse", "v1")[:8]
+	second := git(t, dir, "rev-parse", "HEAD")[:8]
+
+	lines, err := gitlog.Blame(context.Background(), dir, "", "a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []gitlog.BlameLine{
+		{Commit: first, Author: "Jane Doe"},
+		{Commit: first, Author: "Jane Doe"},
+		{Commit: second, Author: "Jane Doe"},
+		{Commit: "", Author: "Not Committed Yet"},
+	}
+	if !slices.Equal(lines, want) {
+		t.Errorf("Blame = %v, want %v", lines, want)
+	}
+
+	// At a revision, the working tree is ignored
+	lines, err = gitlog.Blame(context.Background(), dir, "v1", "a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 2 || lines[1].Commit != first {
+		t.Errorf("Blame at v1 = %v", lines)
+	}
+
+	writeFile(t, dir, "untracked.txt", "x\n")
+	if _, err := gitlog.Blame(context.Background(), dir, "", "untracked.txt"); err == nil {
+		t.Error("blamed an untracked file")
+	}
+}
+
+func TestRevision(t *testing.T) {
+	dir := newRepo(t)
+	ctx := context.Background()
+
+	rev, err := gitlog.ResolveRevision(ctx, dir, "v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := git(t, dir, "rev-parse", "v1^{commit}"); rev.Commit != want || rev.Name != "v1" {
+		t.Errorf("ResolveRevision = %+v, want commit %s", rev, want)
+	}
+	if _, err := gitlog.ResolveRevision(ctx, dir, "--all"); err == nil {
+		t.Error("revision starting with a dash accepted")
+	}
+	if _, err := gitlog.ResolveRevision(ctx, dir, "nope"); err == nil {
+		t.Error("unknown revision resolved")
+	}
+
+	entries, err := gitlog.ListTree(ctx, dir, rev.Commit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, e := range entries {
+		paths = append(paths, e.Path)
+	}
+	if want := []string{"a.txt", "sub/b.txt"}; !slices.Equal(paths, want) {
+		t.Fatalf("ListTree paths = %q, want %q", paths, want)
+	}
+	if entries[0].Size != int64(len("one\ntwo\n")) {
+		t.Errorf("a.txt size = %d", entries[0].Size)
+	}
+
+	blobs, err := gitlog.NewBlobReader(ctx, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer blobs.Close()
+	data, ok, err := blobs.Read(entries[0].Object)
+	if err != nil || !ok || string(data) != "one\ntwo\n" {
+		t.Errorf("Read(a.txt) = %q, %v, %v", data, ok, err)
+	}
+	data, ok, err = blobs.Read("HEAD:a.txt")
+	if err != nil || !ok || string(data) != "one\ntwo\nthree\n" {
+		t.Errorf("Read(HEAD:a.txt) = %q, %v, %v", data, ok, err)
+	}
+	if _, ok, err := blobs.Read("HEAD:missing.txt"); ok || err != nil {
+		t.Errorf("Read(missing) = %v, %v; want not found", ok, err)
+	}
+}
+
+func TestHasRepo(t *testing.T) {
+	dir := newRepo(t)
+	worktree := filepath.Join(t.TempDir(), "wt")
+	git(t, dir, "worktree", "add", "-q", worktree, "v1")
+
+	tests := []struct {
+		name string
+		root string
+		want bool
+	}{
+		{"top level", dir, true},
+		{"gitdir file", worktree, true},
+		{"directory nested in a repository", filepath.Join(dir, "sub"), false},
+		{"plain directory", t.TempDir(), false},
+	}
+	for _, tt := range tests {
+		if got := gitlog.HasRepo(tt.root); got != tt.want {
+			t.Errorf("%s: HasRepo = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
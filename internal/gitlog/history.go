@@ -0,0 +1,59 @@
+package gitlog
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FileHistory holds the commit history summary of a single file
+type FileHistory struct {
+	Commit  string    // Abbreviated hash of the last commit touching the file
+	Time    time.Time // Commit time of that commit
+	Author  string    // Author of that commit
+	Commits int       // Number of commits touching the file
+}
+
+// CollectHistory summarizes the history of every file below root in a
+// single git invocation, keyed by path relative to root (forward slashes)
+func CollectHistory(ctx context.Context, root string) (map[string]*FileHistory, error) {
+	if !HasGitBinary() {
+		return collectHistoryNative(root)
+	}
+
+	lines, err := runLines(ctx, root, "log", "--relative", "--name-only", "--format=%x00%h%x1f%ct%x1f%an")
+	if err != nil {
+		return nil, err
+	}
+
+	history := make(map[string]*FileHistory)
+	var current FileHistory
+	for _, line := range lines {
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, "\x00"):
+			fields := strings.Split(line[1:], "\x1f")
+			if len(fields) != 3 {
+				return nil, fmt.Errorf("unexpected git log line %q", line)
+			}
+			sec, err := strconv.ParseInt(fields[1], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("unexpected git log line %q", line)
+			}
+			current = FileHistory{Commit: fields[0], Time: time.Unix(sec, 0), Author: fields[2]}
+		default:
+			// Log is newest first: the first commit seen per path is the last
+			h, ok := history[line]
+			if !ok {
+				h = &FileHistory{Commit: current.Commit, Time: current.Time, Author: current.Author}
+				history[line] = h
+			}
+			h.Commits++
+		}
+	}
+
+	return history, nil
+}
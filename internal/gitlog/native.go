@@ -0,0 +1,447 @@
+package gitlog
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/neox5/snp/internal/gitrepo"
+)
+
+// ErrNoGit is returned by features that need the git binary when it is
+// not installed
+var ErrNoGit = errors.New("git binary not found in PATH")
+
+var (
+	gitBinaryOnce sync.Once
+	gitBinary     bool
+)
+
+// HasGitBinary reports whether the git binary is available. Without it,
+// the git log, --rev and per-file history fall back to the native reader.
+func HasGitBinary() bool {
+	gitBinaryOnce.Do(func() {
+		_, err := exec.LookPath("git")
+		gitBinary = err == nil
+	})
+	return gitBinary
+}
+
+// collectNative renders the git log section from the object store. The
+// graph is reduced to a "*" per commit in committer date order.
+func collectNative(root string, opts Options) (*GitLogData, error) {
+	repo, err := gitrepo.Discover(root)
+	if err != nil {
+		return nil, err
+	}
+	defer repo.Close()
+
+	starts, hide, err := logTips(repo, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var since time.Time
+	if opts.Since != "" {
+		if since, err = parseSince(opts.Since, time.Now()); err != nil {
+			return nil, err
+		}
+	}
+
+	decorations, err := decorate(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	var commits []*gitrepo.Commit
+	err = repo.Walk(starts, hide, func(c *gitrepo.Commit) error {
+		if !since.IsZero() && c.Committer.When.Before(since) {
+			return gitrepo.ErrStopWalk
+		}
+		commits = append(commits, c)
+		if opts.MaxCount > 0 && len(commits) == opts.MaxCount {
+			return gitrepo.ErrStopWalk
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []string
+	for i, c := range commits {
+		decor := ""
+		if d := decorations[c.Hash]; d != "" {
+			decor = " (" + d + ")"
+		}
+
+		if !opts.Full {
+			lines = append(lines, fmt.Sprintf("* %s%s %s", c.Hash.Short(7), decor, c.Subject()))
+			continue
+		}
+
+		rail := "| "
+		if i == len(commits)-1 {
+			rail = "  "
+		}
+		lines = append(lines,
+			fmt.Sprintf("* commit %s%s", c.Hash, decor),
+			fmt.Sprintf("%sAuthor: %s <%s>", rail, c.Author.Name, c.Author.Email),
+			fmt.Sprintf("%sDate:   %s", rail, c.Author.When.Format("2006-01-02 15:04:05 -0700")),
+			rail,
+		)
+		for _, msg := range strings.Split(strings.TrimRight(c.Message, "\n"), "\n") {
+			lines = append(lines, rail+"    "+msg)
+		}
+		if i < len(commits)-1 {
+			lines = append(lines, rail)
+		}
+	}
+
+	return &GitLogData{Lines: lines}, nil
+}
+
+// logTips returns the commits to start from and to hide for opts
+func logTips(repo *gitrepo.Repo, opts Options) ([]gitrepo.Hash, []gitrepo.Hash, error) {
+	resolve := func(rev string) (gitrepo.Hash, error) {
+		if rev == "" {
+			rev = "HEAD"
+		}
+		c, err := repo.ResolveRevision(rev)
+		if err != nil {
+			return gitrepo.Hash{}, err
+		}
+		return c.Hash, nil
+	}
+
+	if opts.Range != "" {
+		if strings.Contains(opts.Range, "...") {
+			return nil, nil, fmt.Errorf("git log range %q: symmetric ranges require the git binary", opts.Range)
+		}
+		from, to, isRange := strings.Cut(opts.Range, "..")
+		if !isRange {
+			h, err := resolve(opts.Range)
+			return []gitrepo.Hash{h}, nil, err
+		}
+		hide, err := resolve(from)
+		if err != nil {
+			return nil, nil, err
+		}
+		start, err := resolve(to)
+		if err != nil {
+			return nil, nil, err
+		}
+		return []gitrepo.Hash{start}, []gitrepo.Hash{hide}, nil
+	}
+
+	var starts []gitrepo.Hash
+	if head, _, err := repo.Head(); err == nil {
+		starts = append(starts, head)
+	}
+	if !opts.OnlyHEAD {
+		refs, err := repo.Refs()
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, ref := range refs {
+			// Tags may point at non-commits; those are not part of the log
+			if c, err := repo.ReadCommit(ref.Hash); err == nil {
+				starts = append(starts, c.Hash)
+			}
+		}
+	}
+	return starts, nil, nil
+}
+
+// decorate maps commits to their "HEAD -> main, origin/main, tag: v1"
+// decorations, ordered like git: HEAD, branches, remote branches, tags
+func decorate(repo *gitrepo.Repo) (map[gitrepo.Hash]string, error) {
+	names := make(map[gitrepo.Hash][]string)
+
+	refs, err := repo.Refs()
+	if err != nil {
+		return nil, err
+	}
+
+	head, branch, headErr := repo.Head()
+
+	var tags []gitrepo.Ref
+	for _, ref := range refs {
+		c, err := repo.ReadCommit(ref.Hash)
+		if err != nil {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(ref.Name, "refs/heads/"):
+			if ref.Name == branch {
+				continue // shown as "HEAD -> branch"
+			}
+			names[c.Hash] = append(names[c.Hash], strings.TrimPrefix(ref.Name, "refs/heads/"))
+		case strings.HasPrefix(ref.Name, "refs/remotes/"):
+			names[c.Hash] = append(names[c.Hash], strings.TrimPrefix(ref.Name, "refs/remotes/"))
+		case strings.HasPrefix(ref.Name, "refs/tags/"):
+			tags = append(tags, gitrepo.Ref{Name: ref.Name, Hash: c.Hash})
+		}
+	}
+	for _, tag := range tags {
+		names[tag.Hash] = append(names[tag.Hash], "tag: "+strings.TrimPrefix(tag.Name, "refs/tags/"))
+	}
+
+	if headErr == nil {
+		label := "HEAD"
+		if branch != "" {
+			label = "HEAD -> " + strings.TrimPrefix(branch, "refs/heads/")
+		}
+		names[head] = append([]string{label}, names[head]...)
+	}
+
+	decorations := make(map[gitrepo.Hash]string, len(names))
+	for h, n := range names {
+		decorations[h] = strings.Join(n, ", ")
+	}
+	return decorations, nil
+}
+
+// relativeDate matches "2 weeks ago", "3.days", "1 year"
+var relativeDate = regexp.MustCompile(`^(\d+)[ .]*(second|minute|hour|day|week|month|year)s?([ .]+ago)?$`)
+
+// parseSince parses the --since forms the native reader understands:
+// absolute dates ("2024-01-31", "2024-01-31 12:00:00", RFC 3339) and
+// relative dates ("2 weeks ago")
+func parseSince(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(strings.ToLower(s))
+
+	for _, layout := range []string{"2006-01-02", "2006-01-02 15:04:05", "2006-01-02 15:04", time.RFC3339} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+
+	m := relativeDate.FindStringSubmatch(s)
+	if m == nil {
+		return time.Time{}, fmt.Errorf("--since %q is not supported without the git binary", s)
+	}
+	n, _ := strconv.Atoi(m[1])
+	switch m[2] {
+	case "second":
+		return now.Add(-time.Duration(n) * time.Second), nil
+	case "minute":
+		return now.Add(-time.Duration(n) * time.Minute), nil
+	case "hour":
+		return now.Add(-time.Duration(n) * time.Hour), nil
+	case "day":
+		return now.AddDate(0, 0, -n), nil
+	case "week":
+		return now.AddDate(0, 0, -7*n), nil
+	case "month":
+		return now.AddDate(0, -n, 0), nil
+	default:
+		return now.AddDate(-n, 0, 0), nil
+	}
+}
+
+// resolveRevisionNative resolves rev with the native reader
+func resolveRevisionNative(root, rev string) (*Revision, error) {
+	repo, err := gitrepo.Discover(root)
+	if err != nil {
+		return nil, err
+	}
+	defer repo.Close()
+
+	c, err := repo.ResolveRevision(rev)
+	if err != nil {
+		return nil, fmt.Errorf("cannot resolve revision %q: %w", rev, err)
+	}
+	return &Revision{Name: rev, Commit: c.Hash.String(), Time: c.Committer.When}, nil
+}
+
+// listTreeNative lists the regular files of commit below root
+func listTreeNative(root, commit string) ([]TreeEntry, error) {
+	repo, err := gitrepo.Discover(root)
+	if err != nil {
+		return nil, err
+	}
+	defer repo.Close()
+
+	tree, err := rootTree(repo, root, commit)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []TreeEntry
+	err = repo.WalkTree(tree, func(path string, e gitrepo.TreeEntry) error {
+		if !e.IsFile() {
+			return nil
+		}
+		typ, size, err := repo.ReadObjectHeader(e.Hash)
+		if err != nil {
+			return err
+		}
+		if typ != gitrepo.TypeBlob {
+			return fmt.Errorf("%s: expected blob, found %s", path, typ)
+		}
+		entries = append(entries, TreeEntry{Path: path, Object: e.Hash.String(), Size: size})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Match ls-tree, which sorts by full path
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Path < entries[j].Path
+	})
+	return entries, nil
+}
+
+// rootTree returns the tree of rev at the directory root
+func rootTree(repo *gitrepo.Repo, root, rev string) (gitrepo.Hash, error) {
+	c, err := repo.ResolveRevision(rev)
+	if err != nil {
+		return gitrepo.Hash{}, err
+	}
+	prefix, err := repo.Prefix(root)
+	if err != nil {
+		return gitrepo.Hash{}, err
+	}
+	return repo.SubTree(c.Tree, prefix)
+}
+
+// collectHistoryNative summarizes file history like CollectHistory: every
+// non-merge commit reachable from HEAD is diffed against its parent
+func collectHistoryNative(root string) (map[string]*FileHistory, error) {
+	repo, err := gitrepo.Discover(root)
+	if err != nil {
+		return nil, err
+	}
+	defer repo.Close()
+
+	prefix, err := repo.Prefix(root)
+	if err != nil {
+		return nil, err
+	}
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	head, _, err := repo.Head()
+	if err != nil {
+		return nil, err
+	}
+
+	history := make(map[string]*FileHistory)
+	err = repo.Walk([]gitrepo.Hash{head}, nil, func(c *gitrepo.Commit) error {
+		if len(c.Parents) > 1 {
+			return nil
+		}
+
+		var parentTree gitrepo.Hash
+		if len(c.Parents) == 1 {
+			p, err := repo.ReadCommit(c.Parents[0])
+			if err != nil {
+				return err
+			}
+			parentTree = p.Tree
+		}
+
+		paths, err := repo.ChangedPaths(parentTree, c.Tree)
+		if err != nil {
+			return err
+		}
+		for _, path := range paths {
+			rel, ok := strings.CutPrefix(path, prefix)
+			if !ok {
+				continue
+			}
+			// Walk is newest first: the first commit seen per path is the last
+			h, ok := history[rel]
+			if !ok {
+				h = &FileHistory{Commit: c.Hash.Short(7), Time: c.Committer.When, Author: c.Author.Name}
+				history[rel] = h
+			}
+			h.Commits++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return history, nil
+}
+
+// nativeBlobs reads objects for a BlobReader without the git binary
+type nativeBlobs struct {
+	repo *gitrepo.Repo
+	root string
+}
+
+// read resolves an object id or a "<rev>:<path>" expression; "./" paths
+// are relative to root, like in git
+func (n *nativeBlobs) read(name string) ([]byte, bool, error) {
+	rev, path, isPath := strings.Cut(name, ":")
+	if !isPath {
+		h, err := gitrepo.ParseHash(name)
+		if err != nil {
+			return nil, false, err
+		}
+		return n.readBlob(h)
+	}
+
+	dir := ""
+	if rel, ok := strings.CutPrefix(path, "./"); ok {
+		prefix, err := n.repo.Prefix(n.root)
+		if err != nil {
+			return nil, false, err
+		}
+		path = rel
+		if prefix != "" {
+			path = prefix + "/" + rel
+		}
+	}
+	if i := strings.LastIndex(path, "/"); i >= 0 {
+		dir, path = path[:i], path[i+1:]
+	}
+
+	c, err := n.repo.ResolveRevision(rev)
+	if err != nil {
+		return nil, false, err
+	}
+	tree, err := n.repo.SubTree(c.Tree, dir)
+	if errors.Is(err, gitrepo.ErrObjectNotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	entries, err := n.repo.ReadTree(tree)
+	if err != nil {
+		return nil, false, err
+	}
+	for _, e := range entries {
+		if e.Name == path && e.IsFile() {
+			return n.readBlob(e.Hash)
+		}
+	}
+	return nil, false, nil
+}
+
+func (n *nativeBlobs) readBlob(h gitrepo.Hash) ([]byte, bool, error) {
+	typ, data, err := n.repo.ReadObject(h)
+	if errors.Is(err, gitrepo.ErrObjectNotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if typ != gitrepo.TypeBlob {
+		return nil, false, fmt.Errorf("object %s is a %s, not a blob", h, typ)
+	}
+	return data, true, nil
+}
@@ -0,0 +1,105 @@
+package gitlog_test
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/neox5/snp/internal/gitlog"
+)
+
+// Without the git binary, the log, --rev and history read the repository
+// natively and match what git reports
+func TestNativeFallback(t *testing.T) {
+	dir := newRepo(t)
+	git(t, dir, "checkout", "-q", "-b", "topic", "v1")
+	writeFile(t, dir, "topic.txt", "topic\n")
+	git(t, dir, "add", "-A")
+	git(t, dir, "commit", "-q", "-m", "topic work")
+	git(t, dir, "checkout", "-q", "main")
+	git(t, dir, "gc", "-q")
+	writeFile(t, dir, "sub/b.txt", "bee\nloose\n")
+	git(t, dir, "commit", "-q", "-am", "loose")
+	ctx := context.Background()
+
+	type result struct {
+		log     map[string][]string
+		rev     *gitlog.Revision
+		tree    []gitlog.TreeEntry
+		blob    string
+		history map[string]gitlog.FileHistory
+	}
+	collect := func(t *testing.T) result {
+		r := result{log: make(map[string][]string)}
+		for name, opts := range map[string]gitlog.Options{
+			"head":  {OnlyHEAD: true},
+			"max":   {OnlyHEAD: true, MaxCount: 2},
+			"range": {Range: "v1..main"},
+		} {
+			data, err := gitlog.Collect(ctx, dir, opts)
+			if err != nil {
+				t.Fatalf("Collect(%s): %v", name, err)
+			}
+			r.log[name] = data.Lines
+		}
+
+		var err error
+		if r.rev, err = gitlog.ResolveRevision(ctx, dir, "v1"); err != nil {
+			t.Fatal(err)
+		}
+		if r.tree, err = gitlog.ListTree(ctx, dir, "HEAD"); err != nil {
+			t.Fatal(err)
+		}
+		blobs, err := gitlog.NewBlobReader(ctx, dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer blobs.Close()
+		data, ok, err := blobs.Read("topic:topic.txt")
+		if err != nil || !ok {
+			t.Fatalf("Read(topic:topic.txt) = %v, %v", ok, err)
+		}
+		r.blob = string(data)
+
+		history, err := gitlog.CollectHistory(ctx, dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.history = make(map[string]gitlog.FileHistory)
+		for p, h := range history {
+			r.history[p] = *h
+		}
+		return r
+	}
+
+	want := collect(t)
+	gitlog.SetGitBinary(t, false)
+	got := collect(t)
+
+	for name, lines := range want.log {
+		if !slices.Equal(got.log[name], lines) {
+			t.Errorf("log %s:\nnative %q\ngit    %q", name, got.log[name], lines)
+		}
+	}
+	if got.rev.Commit != want.rev.Commit || !got.rev.Time.Equal(want.rev.Time) {
+		t.Errorf("revision: native %+v, git %+v", got.rev, want.rev)
+	}
+	if !slices.Equal(got.tree, want.tree) {
+		t.Errorf("tree:\nnative %+v\ngit    %+v", got.tree, want.tree)
+	}
+	if got.blob != want.blob {
+		t.Errorf("blob: native %q, git %q", got.blob, want.blob)
+	}
+	sameHistory := func(a, b gitlog.FileHistory) bool {
+		return a.Commit == b.Commit && a.Time.Equal(b.Time) && a.Author == b.Author && a.Commits == b.Commits
+	}
+	if !maps.EqualFunc(got.history, want.history, sameHistory) {
+		t.Errorf("history:\nnative %+v\ngit    %+v", got.history, want.history)
+	}
+
+	if _, err := gitlog.Blame(ctx, dir, "", "a.txt"); !errors.Is(err, gitlog.ErrNoGit) {
+		t.Errorf("Blame without git: %v, want ErrNoGit", err)
+	}
+}
@@ -0,0 +1,198 @@
+package gitlog
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/neox5/snp/internal/gitrepo"
+)
+
+// TreeEntry is a file in a revision's tree
+type TreeEntry struct {
+	Path   string // Relative to root, forward slashes
+	Object string // Blob object id
+	Size   int64
+}
+
+// Revision describes a resolved commit-ish
+type Revision struct {
+	Name   string    // As given, e.g. "v1.2.0"
+	Commit string    // Full commit hash
+	Time   time.Time // Committer date
+}
+
+// ResolveRevision resolves rev to a commit and its commit time
+func ResolveRevision(ctx context.Context, root, rev string) (*Revision, error) {
+	if rev == "" || strings.HasPrefix(rev, "-") {
+		return nil, fmt.Errorf("invalid revision %q", rev)
+	}
+	if !HasGitBinary() {
+		return resolveRevisionNative(root, rev)
+	}
+
+	lines, err := runLines(ctx, root, "show", "-s", "--format=%H %ct", rev+"^{commit}")
+	if err != nil {
+		return nil, fmt.Errorf("cannot resolve revision %q: %w", rev, err)
+	}
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("cannot resolve revision %q", rev)
+	}
+
+	fields := strings.Fields(lines[len(lines)-1])
+	if len(fields) != 2 {
+		return nil, fmt.Errorf("unexpected git show output %q", lines[len(lines)-1])
+	}
+	sec, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected commit time %q", fields[1])
+	}
+
+	return &Revision{Name: rev, Commit: fields[0], Time: time.Unix(sec, 0)}, nil
+}
+
+// ListTree lists the regular files of commit below root. Symlinks and
+// submodule entries are skipped.
+func ListTree(ctx context.Context, root, commit string) ([]TreeEntry, error) {
+	if !HasGitBinary() {
+		return listTreeNative(root, commit)
+	}
+
+	out, err := run(ctx, root, "ls-tree", "-r", "-l", "-z", commit)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []TreeEntry
+	for _, record := range strings.Split(string(out), "\x00") {
+		if record == "" {
+			continue
+		}
+
+		// <mode> SP <type> SP <object> SP+ <size> TAB <path>
+		meta, path, ok := strings.Cut(record, "\t")
+		if !ok {
+			return nil, fmt.Errorf("unexpected ls-tree record %q", record)
+		}
+		fields := strings.Fields(meta)
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("unexpected ls-tree record %q", record)
+		}
+		mode, typ, object, sizeStr := fields[0], fields[1], fields[2], fields[3]
+		if typ != "blob" || mode == "120000" {
+			continue
+		}
+
+		size, err := strconv.ParseInt(sizeStr, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected ls-tree size %q", sizeStr)
+		}
+
+		entries = append(entries, TreeEntry{Path: path, Object: object, Size: size})
+	}
+
+	return entries, nil
+}
+
+// BlobReader reads objects through a long-running "git cat-file --batch",
+// or natively when the git binary is not installed
+type BlobReader struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+	stderr bytes.Buffer
+
+	native *nativeBlobs
+}
+
+// NewBlobReader starts a batch object reader for the repository at root
+func NewBlobReader(ctx context.Context, root string) (*BlobReader, error) {
+	if !HasGitBinary() {
+		repo, err := gitrepo.Discover(root)
+		if err != nil {
+			return nil, err
+		}
+		return &BlobReader{native: &nativeBlobs{repo: repo, root: root}}, nil
+	}
+
+	r := &BlobReader{}
+	cmd := exec.CommandContext(ctx, "git", "-C", root, "cat-file", "--batch")
+	cmd.Stderr = &r.stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	r.cmd, r.stdin, r.stdout = cmd, stdin, bufio.NewReader(stdout)
+	return r, nil
+}
+
+// Read returns the content of the named object (an object id or any
+// revision expression like "v1.0:./.gitignore"). Reports false if missing.
+func (r *BlobReader) Read(name string) ([]byte, bool, error) {
+	if strings.ContainsAny(name, "\n") {
+		return nil, false, fmt.Errorf("invalid object name %q", name)
+	}
+	if r.native != nil {
+		return r.native.read(name)
+	}
+
+	if _, err := io.WriteString(r.stdin, name+"\n"); err != nil {
+		return nil, false, err
+	}
+
+	header, err := r.stdout.ReadString('\n')
+	if err != nil {
+		return nil, false, err
+	}
+	header = strings.TrimSuffix(header, "\n")
+
+	if strings.HasSuffix(header, " missing") || strings.HasSuffix(header, " ambiguous") {
+		return nil, false, nil
+	}
+
+	// <oid> SP <type> SP <size>
+	fields := strings.Fields(header)
+	if len(fields) != 3 {
+		return nil, false, fmt.Errorf("unexpected cat-file header %q", header)
+	}
+	size, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return nil, false, fmt.Errorf("unexpected cat-file header %q", header)
+	}
+
+	// Content is followed by a newline
+	buf := make([]byte, size+1)
+	if _, err := io.ReadFull(r.stdout, buf); err != nil {
+		return nil, false, err
+	}
+
+	return buf[:size], true, nil
+}
+
+// Close stops the batch process
+func (r *BlobReader) Close() error {
+	if r.native != nil {
+		return r.native.repo.Close()
+	}
+
+	r.stdin.Close()
+	if err := r.cmd.Wait(); err != nil {
+		return commandError("cat-file", err, &r.stderr)
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+package gitlog
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Working tree status marks shown in the file index
+const (
+	StatusModified  = "modified"
+	StatusAdded     = "added"
+	StatusRenamed   = "renamed"
+	StatusUntracked = "untracked"
+)
+
+// WorkTree holds uncommitted changes of a working tree
+type WorkTree struct {
+	StatusLines  []string          // git status --short output
+	StagedDiff   []string          // git diff --cached
+	UnstagedDiff []string          // git diff
+	FileStatus   map[string]string // Status mark per path relative to root
+}
+
+// CollectWorkTree retrieves status and diffs for the working tree at root.
+// Paths are relative to root, which may be a subdirectory of the repository.
+func CollectWorkTree(ctx context.Context, root string) (*WorkTree, error) {
+	wt := &WorkTree{}
+	var err error
+
+	wt.StatusLines, err = runLines(ctx, root, "status", "--short", "--untracked-files=all", ".")
+	if err != nil {
+		return nil, err
+	}
+
+	wt.StagedDiff, err = runLines(ctx, root, "diff", "--cached", "--relative")
+	if err != nil {
+		return nil, err
+	}
+
+	wt.UnstagedDiff, err = runLines(ctx, root, "diff", "--relative")
+	if err != nil {
+		return nil, err
+	}
+
+	wt.FileStatus, err = FileStatus(ctx, root)
+	if err != nil {
+		return nil, err
+	}
+
+	return wt, nil
+}
+
+// Restrict drops status lines, diffs and status marks of paths keep
+// rejects, such as files excluded from the snapshot. git reports changes
+// for the whole tree below root, ignoring the snapshot's filters.
+func (wt *WorkTree) Restrict(keep func(relPath string) bool) {
+	var status []string
+	for _, line := range wt.StatusLines {
+		if keep(statusPath(line)) {
+			status = append(status, line)
+		}
+	}
+	wt.StatusLines = status
+
+	wt.StagedDiff = filterDiff(wt.StagedDiff, keep)
+	wt.UnstagedDiff = filterDiff(wt.UnstagedDiff, keep)
+
+	for p := range wt.FileStatus {
+		if !keep(p) {
+			delete(wt.FileStatus, p)
+		}
+	}
+}
+
+// statusPath returns the path of a "git status --short" line, the new
+// path for renames
+func statusPath(line string) string {
+	if len(line) < 4 {
+		return ""
+	}
+	p := line[3:]
+	if i := strings.LastIndex(p, " -> "); i >= 0 {
+		p = p[i+len(" -> "):]
+	}
+	if unquoted, err := strconv.Unquote(p); err == nil && strings.HasPrefix(p, `"`) {
+		p = unquoted
+	}
+	return p
+}
+
+// FileStatus maps paths relative to root to status marks using porcelain
+// output, which reports paths relative to the repository top level.
+// Clean files are absent from the map.
+func FileStatus(ctx context.Context, root string) (map[string]string, error) {
+	prefixLines, err := runLines(ctx, root, "rev-parse", "--show-prefix")
+	if err != nil {
+		return nil, err
+	}
+	prefix := ""
+	if len(prefixLines) > 0 {
+		prefix = prefixLines[0]
+	}
+
+	out, err := run(ctx, root, "status", "--porcelain=v1", "-z", "--untracked-files=all", ".")
+	if err != nil {
+		return nil, err
+	}
+
+	status := make(map[string]string)
+	entries := strings.Split(string(out), "\x00")
+	for i := 0; i < len(entries); i++ {
+		entry := entries[i]
+		if len(entry) < 4 {
+			continue
+		}
+		x, y, path := entry[0], entry[1], entry[3:]
+
+		// Renames and copies are followed by the original path
+		if x == 'R' || x == 'C' {
+			i++
+		}
+
+		rel, ok := strings.CutPrefix(path, prefix)
+		if !ok {
+			continue
+		}
+
+		switch {
+		case x == '?':
+			status[rel] = StatusUntracked
+		case x == 'A':
+			status[rel] = StatusAdded
+		case x == 'R' || x == 'C':
+			status[rel] = StatusRenamed
+		case x == 'M' || y == 'M' || x == 'T' || y == 'T':
+			status[rel] = StatusModified
+		}
+	}
+
+	return status, nil
+}
+
+// run executes git in root and returns its standard output. Error output
+// is captured into the returned error instead of the terminal.
+func run(ctx context.Context, root string, args ...string) ([]byte, error) {
+	if !HasGitBinary() {
+		return nil, fmt.Errorf("git %s: %w", args[0], ErrNoGit)
+	}
+
+	var buf, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", root, "-c", "core.quotePath=false"}, args...)...)
+	cmd.Stdout = &buf
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, commandError(args[0], err, &stderr)
+	}
+	return buf.Bytes(), nil
+}
+
+// commandError describes a failed git command by its error output
+func commandError(subcommand string, err error, stderr *bytes.Buffer) error {
+	msg := strings.TrimSpace(stderr.String())
+	if msg == "" {
+		return fmt.Errorf("git %s: %w", subcommand, err)
+	}
+	// Prefer the "fatal:" or "error:" reason over usage hints
+	lines := strings.Split(msg, "\n")
+	for _, line := range lines {
+		for _, prefix := range []string{"fatal: ", "error: "} {
+			if reason, ok := strings.CutPrefix(line, prefix); ok {
+				return fmt.Errorf("git %s: %s", subcommand, reason)
+			}
+		}
+	}
+	return fmt.Errorf("git %s: %s", subcommand, lines[0])
+}
+
+// runLines executes git in root and returns its output as lines
+func runLines(ctx context.Context, root string, args ...string) ([]string, error) {
+	out, err := run(ctx, root, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines, scanner.Err()
+}
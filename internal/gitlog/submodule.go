@@ -0,0 +1,83 @@
+package gitlog
+
+import (
+	"errors"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/neox5/snp/internal/gitrepo"
+)
+
+// gitlinkMode is the tree entry mode of a submodule commit
+const gitlinkMode = "160000"
+
+// Submodule is a nested repository below the snapshot root
+type Submodule struct {
+	Path     string // Relative to root, forward slashes
+	Pinned   string // Commit recorded in the superproject's HEAD, empty if not a submodule
+	Checkout string // Commit checked out in the nested repository, empty if unknown
+}
+
+// CollectSubmodules reads the pinned and checked-out commits of the nested
+// repositories at paths (relative to root) without invoking git
+func CollectSubmodules(root string, paths []string) ([]Submodule, error) {
+	var tree gitrepo.Hash
+	var super *gitrepo.Repo
+	if repo, err := gitrepo.Discover(root); err == nil {
+		defer repo.Close()
+		if c, err := repo.ResolveRevision("HEAD"); err == nil {
+			if tree, err = rootTree(repo, root, c.Hash.String()); err == nil {
+				super = repo
+			}
+		}
+	}
+
+	var subs []Submodule
+	for _, p := range paths {
+		sub := Submodule{Path: p}
+
+		if super != nil {
+			pinned, err := gitlink(super, tree, p)
+			if err != nil {
+				return nil, err
+			}
+			sub.Pinned = pinned
+		}
+
+		if repo, err := gitrepo.Discover(filepath.Join(root, filepath.FromSlash(p))); err == nil {
+			if head, _, err := repo.Head(); err == nil {
+				sub.Checkout = head.String()
+			}
+			repo.Close()
+		}
+
+		subs = append(subs, sub)
+	}
+
+	return subs, nil
+}
+
+// gitlink returns the commit recorded for the submodule at relPath below
+// tree, or "" if there is no gitlink entry
+func gitlink(repo *gitrepo.Repo, tree gitrepo.Hash, relPath string) (string, error) {
+	dir, name := path.Split(relPath)
+	subtree, err := repo.SubTree(tree, strings.TrimSuffix(dir, "/"))
+	if errors.Is(err, gitrepo.ErrObjectNotFound) {
+		return "", nil // parent directory is not tracked
+	}
+	if err != nil {
+		return "", err
+	}
+
+	entries, err := repo.ReadTree(subtree)
+	if err != nil {
+		return "", err
+	}
+	for _, e := range entries {
+		if e.Name == name && e.Mode == gitlinkMode {
+			return e.Hash.String(), nil
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,240 @@
+package gitrepo
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Signature is a commit author or committer
+type Signature struct {
+	Name  string
+	Email string
+	When  time.Time // In the signer's time zone
+}
+
+// Commit is a parsed commit object
+type Commit struct {
+	Hash      Hash
+	Tree      Hash
+	Parents   []Hash
+	Author    Signature
+	Committer Signature
+	Message   string
+}
+
+// Subject returns the first line of the commit message
+func (c *Commit) Subject() string {
+	subject, _, _ := strings.Cut(c.Message, "\n")
+	return subject
+}
+
+// ReadCommit reads and parses a commit, peeling annotated tags
+func (r *Repo) ReadCommit(h Hash) (*Commit, error) {
+	for depth := 0; depth < maxSymrefDepth; depth++ {
+		typ, data, err := r.ReadObject(h)
+		if err != nil {
+			return nil, err
+		}
+
+		switch typ {
+		case TypeCommit:
+			return parseCommit(h, data)
+		case TypeTag:
+			if h, err = tagTarget(data); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("object %s is a %s, not a commit", h, typ)
+		}
+	}
+	return nil, fmt.Errorf("object %s: too many levels of tags", h)
+}
+
+// parseCommit parses header lines up to the first blank line, then the
+// message
+func parseCommit(h Hash, data []byte) (*Commit, error) {
+	c := &Commit{Hash: h}
+
+	headers, message, _ := bytes.Cut(data, []byte("\n\n"))
+	c.Message = string(message)
+
+	for _, line := range strings.Split(string(headers), "\n") {
+		key, value, _ := strings.Cut(line, " ")
+
+		var err error
+		switch key {
+		case "tree":
+			c.Tree, err = ParseHash(value)
+		case "parent":
+			var p Hash
+			p, err = ParseHash(value)
+			c.Parents = append(c.Parents, p)
+		case "author":
+			c.Author, err = parseSignature(value)
+		case "committer":
+			c.Committer, err = parseSignature(value)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("commit %s: %w", h, err)
+		}
+	}
+
+	return c, nil
+}
+
+// parseSignature parses "Name <email> <unix-seconds> <+hhmm>"
+func parseSignature(s string) (Signature, error) {
+	open := strings.LastIndexByte(s, '<')
+	end := strings.LastIndexByte(s, '>')
+	if open < 0 || end < open {
+		return Signature{}, fmt.Errorf("malformed signature %q", s)
+	}
+
+	sig := Signature{
+		Name:  strings.TrimSpace(s[:open]),
+		Email: s[open+1 : end],
+	}
+
+	fields := strings.Fields(s[end+1:])
+	if len(fields) != 2 {
+		return Signature{}, fmt.Errorf("malformed signature %q", s)
+	}
+	sec, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return Signature{}, fmt.Errorf("malformed signature time %q", s)
+	}
+
+	tz := fields[1]
+	if len(tz) != 5 {
+		return Signature{}, fmt.Errorf("malformed signature zone %q", s)
+	}
+	hours, err1 := strconv.Atoi(tz[1:3])
+	minutes, err2 := strconv.Atoi(tz[3:5])
+	if err1 != nil || err2 != nil {
+		return Signature{}, fmt.Errorf("malformed signature zone %q", s)
+	}
+	offset := hours*3600 + minutes*60
+	if tz[0] == '-' {
+		offset = -offset
+	}
+
+	sig.When = time.Unix(sec, 0).In(time.FixedZone(tz, offset))
+	return sig, nil
+}
+
+// tagTarget returns the object an annotated tag points to
+func tagTarget(data []byte) (Hash, error) {
+	for _, line := range strings.Split(string(data), "\n") {
+		if value, ok := strings.CutPrefix(line, "object "); ok {
+			return ParseHash(value)
+		}
+		if line == "" {
+			break
+		}
+	}
+	return Hash{}, fmt.Errorf("malformed tag object")
+}
+
+// TreeEntry is an entry of a tree object
+type TreeEntry struct {
+	Mode string // Octal mode, e.g. "100644", "40000", "120000", "160000"
+	Name string
+	Hash Hash
+}
+
+// IsDir reports whether the entry is a subtree
+func (e TreeEntry) IsDir() bool {
+	return e.Mode == "40000"
+}
+
+// IsFile reports whether the entry is a regular (possibly executable) file
+func (e TreeEntry) IsFile() bool {
+	return e.Mode == "100644" || e.Mode == "100755" || e.Mode == "100664"
+}
+
+// ReadTree reads and parses a tree object
+func (r *Repo) ReadTree(h Hash) ([]TreeEntry, error) {
+	typ, data, err := r.ReadObject(h)
+	if err != nil {
+		return nil, err
+	}
+	if typ != TypeTree {
+		return nil, fmt.Errorf("object %s is a %s, not a tree", h, typ)
+	}
+
+	// <mode> SP <name> NUL <20-byte id>
+	var entries []TreeEntry
+	for len(data) > 0 {
+		header, rest, ok := bytes.Cut(data, []byte{0})
+		if !ok || len(rest) < len(Hash{}) {
+			return nil, fmt.Errorf("tree %s: malformed entry", h)
+		}
+		mode, name, ok := strings.Cut(string(header), " ")
+		if !ok {
+			return nil, fmt.Errorf("tree %s: malformed entry", h)
+		}
+
+		e := TreeEntry{Mode: mode, Name: name}
+		copy(e.Hash[:], rest)
+		entries = append(entries, e)
+		data = rest[len(Hash{}):]
+	}
+	return entries, nil
+}
+
+// SubTree returns the tree at the slash-separated path below tree
+// ("" returns tree itself)
+func (r *Repo) SubTree(tree Hash, path string) (Hash, error) {
+	if path == "" {
+		return tree, nil
+	}
+
+	for _, name := range strings.Split(path, "/") {
+		entries, err := r.ReadTree(tree)
+		if err != nil {
+			return Hash{}, err
+		}
+
+		found := false
+		for _, e := range entries {
+			if e.Name == name && e.IsDir() {
+				tree, found = e.Hash, true
+				break
+			}
+		}
+		if !found {
+			return Hash{}, fmt.Errorf("path %s: %w", path, ErrObjectNotFound)
+		}
+	}
+	return tree, nil
+}
+
+// WalkTree calls fn for every non-tree entry below tree, with paths
+// relative to tree. Entries are visited in tree order.
+func (r *Repo) WalkTree(tree Hash, fn func(path string, e TreeEntry) error) error {
+	return r.walkTree(tree, "", fn)
+}
+
+func (r *Repo) walkTree(tree Hash, prefix string, fn func(string, TreeEntry) error) error {
+	entries, err := r.ReadTree(tree)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		path := prefix + e.Name
+		if e.IsDir() {
+			if err := r.walkTree(e.Hash, path+"/", fn); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := fn(path, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
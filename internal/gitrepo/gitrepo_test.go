@@ -0,0 +1,237 @@
+package gitrepo_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/neox5/snp/internal/gitrepo"
+)
+
+// git runs the git binary in dir and returns trimmed output
+func git(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=Test", "GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_COMMITTER_NAME=Test", "GIT_COMMITTER_EMAIL=test@example.com",
+	)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// newRepo creates a repository with packed (deltified) and loose commits
+func newRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git binary not available")
+	}
+
+	dir := t.TempDir()
+	git(t, dir, "init", "-q", "-b", "main")
+	for i := 1; i <= 4; i++ {
+		var b strings.Builder
+		for n := 0; n < i*100; n++ {
+			b.WriteString("line " + strconv.Itoa(n) + "\n")
+		}
+		if err := os.MkdirAll(filepath.Join(dir, "sub"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "sub", "a.txt"), []byte(b.String()), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		git(t, dir, "add", "-A")
+		git(t, dir, "commit", "-q", "-m", "commit "+strconv.Itoa(i))
+	}
+	git(t, dir, "tag", "-a", "v1", "-m", "release", "HEAD~1")
+	git(t, dir, "gc", "-q")
+
+	if err := os.WriteFile(filepath.Join(dir, "b.txt"), []byte("loose\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	git(t, dir, "add", "-A")
+	git(t, dir, "commit", "-q", "-m", "loose commit")
+	return dir
+}
+
+func TestResolveRevision(t *testing.T) {
+	dir := newRepo(t)
+
+	repo, err := gitrepo.Discover(filepath.Join(dir, "sub"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+
+	for _, rev := range []string{"HEAD", "main~2", "v1", "v1^", git(t, dir, "rev-parse", "--short", "HEAD~3")} {
+		c, err := repo.ResolveRevision(rev)
+		if err != nil {
+			t.Fatalf("ResolveRevision(%q): %v", rev, err)
+		}
+		if want := git(t, dir, "rev-parse", rev+"^{commit}"); c.Hash.String() != want {
+			t.Errorf("ResolveRevision(%q) = %s, want %s", rev, c.Hash, want)
+		}
+	}
+}
+
+func TestReadBlobFromPack(t *testing.T) {
+	dir := newRepo(t)
+
+	repo, err := gitrepo.Discover(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+
+	for _, rev := range []string{"HEAD", "HEAD~2", "HEAD~4"} {
+		c, err := repo.ResolveRevision(rev)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tree, err := repo.SubTree(c.Tree, "sub")
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries, err := repo.ReadTree(tree)
+		if err != nil || len(entries) != 1 {
+			t.Fatalf("ReadTree(%s:sub) = %v, %v", rev, entries, err)
+		}
+		_, data, err := repo.ReadObject(entries[0].Hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := git(t, dir, "show", rev+":sub/a.txt"); strings.TrimSpace(string(data)) != want {
+			t.Errorf("%s:sub/a.txt differs from git show", rev)
+		}
+	}
+}
+
+func TestReadObjectHeader(t *testing.T) {
+	dir := newRepo(t)
+
+	repo, err := gitrepo.Discover(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+
+	// Every object, packed, deltified or loose, matches git's type and size
+	out := git(t, dir, "cat-file", "--batch-all-objects", "--batch-check")
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		h, err := gitrepo.ParseHash(fields[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		typ, size, err := repo.ReadObjectHeader(h)
+		if err != nil {
+			t.Fatalf("%s: %v", fields[0], err)
+		}
+		if got := typ + " " + strconv.FormatInt(size, 10); got != fields[1]+" "+fields[2] {
+			t.Errorf("%s: header %q, git reports %q", fields[0], got, fields[1]+" "+fields[2])
+		}
+	}
+}
+
+func TestDiscoverGitFile(t *testing.T) {
+	dir := newRepo(t)
+	wt := filepath.Join(t.TempDir(), "wt")
+	git(t, dir, "worktree", "add", "-q", "-b", "feature", wt)
+
+	repo, err := gitrepo.Discover(wt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+
+	head, branch, err := repo.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if branch != "refs/heads/feature" {
+		t.Errorf("branch = %q, want refs/heads/feature", branch)
+	}
+	if want := git(t, wt, "rev-parse", "HEAD"); head.String() != want {
+		t.Errorf("HEAD = %s, want %s", head, want)
+	}
+}
+
+func TestWalk_StopsEarly(t *testing.T) {
+	dir := newRepo(t)
+
+	repo, err := gitrepo.Discover(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	head, _, err := repo.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var all []string
+	err = repo.Walk([]gitrepo.Hash{head}, nil, func(c *gitrepo.Commit) error {
+		all = append(all, c.Subject())
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := strings.Split(git(t, dir, "log", "--format=%s"), "\n"); !slices.Equal(all, want) {
+		t.Errorf("walk = %q, want %q", all, want)
+	}
+
+	var hidden []string
+	v1, err := repo.ResolveRevision("v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = repo.Walk([]gitrepo.Hash{head}, []gitrepo.Hash{v1.Hash}, func(c *gitrepo.Commit) error {
+		hidden = append(hidden, c.Subject())
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := strings.Split(git(t, dir, "log", "--format=%s", "v1..HEAD"), "\n"); !slices.Equal(hidden, want) {
+		t.Errorf("walk v1..HEAD = %q, want %q", hidden, want)
+	}
+
+	// The loose HEAD commit is the only one read: the packed history may
+	// be missing entirely
+	for _, p := range mustGlob(t, filepath.Join(dir, ".git", "objects", "pack", "*")) {
+		if err := os.Remove(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	repo, err = gitrepo.Discover(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	n := 0
+	err = repo.Walk([]gitrepo.Hash{head}, nil, func(c *gitrepo.Commit) error {
+		n++
+		return gitrepo.ErrStopWalk
+	})
+	if err != nil || n != 1 {
+		t.Errorf("walk stopped after one commit: %d commits, %v", n, err)
+	}
+}
+
+// mustGlob returns the paths matching pattern
+func mustGlob(t *testing.T, pattern string) []string {
+	t.Helper()
+	paths, err := filepath.Glob(pattern)
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("glob %s: %v, %d matches", pattern, err, len(paths))
+	}
+	return paths
+}
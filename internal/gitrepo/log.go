@@ -0,0 +1,185 @@
+package gitrepo
+
+import (
+	"container/heap"
+	"errors"
+	"sort"
+)
+
+// ErrStopWalk stops Walk early without reporting an error
+var ErrStopWalk = errors.New("stop walk")
+
+// Walk calls fn for every commit reachable from starts but not from hide.
+// Commits are visited newest committer date first, reading parents only as
+// the walk reaches them (like "git log" without ordering options), so a
+// walk stopped early reads no more of the history than it needs. Returning
+// ErrStopWalk from fn ends the walk.
+func (r *Repo) Walk(starts, hide []Hash, fn func(*Commit) error) error {
+	queue := &commitQueue{seq: make(map[Hash]int)}
+	hidden := make(map[Hash]bool)
+	counted := make(map[Hash]bool) // Queued while not hidden
+	interesting := 0               // Counted commits still queued
+
+	push := func(h Hash, hide bool) error {
+		if hide {
+			hidden[h] = true
+		}
+		if _, seen := queue.seq[h]; seen {
+			return nil
+		}
+		c, err := r.ReadCommit(h)
+		if err != nil {
+			return err
+		}
+		queue.seq[h] = len(queue.seq)
+		if !hidden[h] {
+			counted[h] = true
+			interesting++
+		}
+		heap.Push(queue, c)
+		return nil
+	}
+	for _, h := range hide {
+		if err := push(h, true); err != nil {
+			return err
+		}
+	}
+	for _, h := range starts {
+		if err := push(h, false); err != nil {
+			return err
+		}
+	}
+
+	// Hidden commits are walked too, to hide their ancestors, until only
+	// hidden commits are left
+	for interesting > 0 {
+		c := heap.Pop(queue).(*Commit)
+		if counted[c.Hash] {
+			interesting--
+		}
+		if hidden[c.Hash] {
+			for _, p := range c.Parents {
+				if err := push(p, true); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		if err := fn(c); err != nil {
+			if errors.Is(err, ErrStopWalk) {
+				return nil
+			}
+			return err
+		}
+		for _, p := range c.Parents {
+			if err := push(p, false); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// commitQueue is a max-heap of commits by committer date; ties go to the
+// commit discovered first
+type commitQueue struct {
+	items []*Commit
+	seq   map[Hash]int
+}
+
+func (q *commitQueue) Len() int { return len(q.items) }
+func (q *commitQueue) Less(i, j int) bool {
+	a, b := q.items[i], q.items[j]
+	if !a.Committer.When.Equal(b.Committer.When) {
+		return a.Committer.When.After(b.Committer.When)
+	}
+	return q.seq[a.Hash] < q.seq[b.Hash]
+}
+func (q *commitQueue) Swap(i, j int) { q.items[i], q.items[j] = q.items[j], q.items[i] }
+func (q *commitQueue) Push(x any)    { q.items = append(q.items, x.(*Commit)) }
+func (q *commitQueue) Pop() any {
+	c := q.items[len(q.items)-1]
+	q.items = q.items[:len(q.items)-1]
+	return c
+}
+
+// ChangedPaths lists the non-tree paths that differ between two trees,
+// sorted. A zero from hash stands for the empty tree.
+func (r *Repo) ChangedPaths(from, to Hash) ([]string, error) {
+	var paths []string
+	if err := r.diffTrees(from, to, "", &paths); err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+func (r *Repo) diffTrees(from, to Hash, prefix string, paths *[]string) error {
+	if from == to {
+		return nil
+	}
+
+	entries := func(h Hash) (map[string]TreeEntry, error) {
+		m := make(map[string]TreeEntry)
+		if h == (Hash{}) {
+			return m, nil
+		}
+		list, err := r.ReadTree(h)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range list {
+			m[e.Name] = e
+		}
+		return m, nil
+	}
+
+	a, err := entries(from)
+	if err != nil {
+		return err
+	}
+	b, err := entries(to)
+	if err != nil {
+		return err
+	}
+
+	// visit reports one side of a changed name; subtrees recurse
+	visit := func(name string, old, cur TreeEntry, hasOld, hasCur bool) error {
+		var oldTree, curTree Hash
+		if hasOld && old.IsDir() {
+			oldTree = old.Hash
+		}
+		if hasCur && cur.IsDir() {
+			curTree = cur.Hash
+		}
+		if oldTree != (Hash{}) || curTree != (Hash{}) {
+			if err := r.diffTrees(oldTree, curTree, prefix+name+"/", paths); err != nil {
+				return err
+			}
+		}
+		if (hasOld && !old.IsDir()) || (hasCur && !cur.IsDir()) {
+			*paths = append(*paths, prefix+name)
+		}
+		return nil
+	}
+
+	for name, old := range a {
+		cur, ok := b[name]
+		if ok && cur.Hash == old.Hash && cur.Mode == old.Mode {
+			continue
+		}
+		if err := visit(name, old, cur, true, ok); err != nil {
+			return err
+		}
+	}
+	for name, cur := range b {
+		if _, ok := a[name]; ok {
+			continue
+		}
+		if err := visit(name, TreeEntry{}, cur, false, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
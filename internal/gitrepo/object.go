@@ -0,0 +1,735 @@
+package gitrepo
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Hash is a SHA-1 object id
+type Hash [20]byte
+
+// ParseHash parses a 40-character hex object id
+func ParseHash(s string) (Hash, error) {
+	var h Hash
+	if len(s) != 2*len(h) {
+		return h, fmt.Errorf("invalid object id %q", s)
+	}
+	if _, err := hex.Decode(h[:], []byte(s)); err != nil {
+		return h, fmt.Errorf("invalid object id %q", s)
+	}
+	return h, nil
+}
+
+// String returns the full hex object id
+func (h Hash) String() string {
+	return hex.EncodeToString(h[:])
+}
+
+// Short returns the abbreviated hex object id
+func (h Hash) Short(n int) string {
+	return h.String()[:n]
+}
+
+// Object types
+const (
+	TypeCommit = "commit"
+	TypeTree   = "tree"
+	TypeBlob   = "blob"
+	TypeTag    = "tag"
+)
+
+// ErrObjectNotFound is returned when an object is in neither loose nor
+// packed storage
+var ErrObjectNotFound = errors.New("object not found")
+
+// ReadObject returns the type and content of an object
+func (r *Repo) ReadObject(h Hash) (string, []byte, error) {
+	return r.objects.read(h)
+}
+
+// ReadObjectHeader returns the type and size of an object without
+// inflating its content
+func (r *Repo) ReadObjectHeader(h Hash) (string, int64, error) {
+	return r.objects.header(h)
+}
+
+// objectStore reads loose and packed objects from an objects directory
+// and its alternates
+type objectStore struct {
+	dir   string
+	packs []*pack
+
+	loaded     bool
+	alternates []*objectStore
+}
+
+func newObjectStore(dir string) *objectStore {
+	return &objectStore{dir: dir}
+}
+
+// load opens the pack indexes and alternates on first use
+func (s *objectStore) load() error {
+	if s.loaded {
+		return nil
+	}
+	s.loaded = true
+
+	idxFiles, err := filepath.Glob(filepath.Join(s.dir, "pack", "*.idx"))
+	if err != nil {
+		return err
+	}
+	for _, idx := range idxFiles {
+		p, err := openPack(strings.TrimSuffix(idx, ".idx"))
+		if err != nil {
+			return err
+		}
+		s.packs = append(s.packs, p)
+	}
+
+	b, err := os.ReadFile(filepath.Join(s.dir, "info", "alternates"))
+	if err == nil {
+		for _, line := range strings.Split(string(b), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || line[0] == '#' {
+				continue
+			}
+			if !filepath.IsAbs(line) {
+				line = filepath.Join(s.dir, line)
+			}
+			s.alternates = append(s.alternates, newObjectStore(line))
+		}
+	}
+	return nil
+}
+
+func (s *objectStore) read(h Hash) (string, []byte, error) {
+	if err := s.load(); err != nil {
+		return "", nil, err
+	}
+
+	typ, data, err := s.readLoose(h)
+	if err == nil || !errors.Is(err, ErrObjectNotFound) {
+		return typ, data, err
+	}
+
+	for _, p := range s.packs {
+		if offset, ok := p.find(h); ok {
+			return p.readAt(offset, s)
+		}
+	}
+
+	for _, alt := range s.alternates {
+		typ, data, err := alt.read(h)
+		if err == nil || !errors.Is(err, ErrObjectNotFound) {
+			return typ, data, err
+		}
+	}
+
+	return "", nil, fmt.Errorf("%s: %w", h, ErrObjectNotFound)
+}
+
+func (s *objectStore) header(h Hash) (string, int64, error) {
+	if err := s.load(); err != nil {
+		return "", 0, err
+	}
+
+	typ, size, err := s.readLooseHeader(h)
+	if err == nil || !errors.Is(err, ErrObjectNotFound) {
+		return typ, size, err
+	}
+
+	for _, p := range s.packs {
+		if offset, ok := p.find(h); ok {
+			return p.headerAt(offset, s, 0)
+		}
+	}
+
+	for _, alt := range s.alternates {
+		typ, size, err := alt.header(h)
+		if err == nil || !errors.Is(err, ErrObjectNotFound) {
+			return typ, size, err
+		}
+	}
+
+	return "", 0, fmt.Errorf("%s: %w", h, ErrObjectNotFound)
+}
+
+// readLooseHeader inflates a loose object only up to the end of its
+// "<type> <size>\x00" header
+func (s *objectStore) readLooseHeader(h Hash) (string, int64, error) {
+	hexID := h.String()
+	f, err := os.Open(filepath.Join(s.dir, hexID[:2], hexID[2:]))
+	if os.IsNotExist(err) {
+		return "", 0, fmt.Errorf("%s: %w", h, ErrObjectNotFound)
+	}
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	zr, err := zlib.NewReader(f)
+	if err != nil {
+		return "", 0, fmt.Errorf("object %s: %w", h, err)
+	}
+	defer zr.Close()
+
+	var header []byte
+	var c [1]byte
+	for len(header) < 64 {
+		if _, err := io.ReadFull(zr, c[:]); err != nil {
+			return "", 0, fmt.Errorf("object %s: %w", h, err)
+		}
+		if c[0] == 0 {
+			typ, sizeStr, ok := strings.Cut(string(header), " ")
+			size, err := strconv.ParseInt(sizeStr, 10, 64)
+			if !ok || err != nil {
+				break
+			}
+			return typ, size, nil
+		}
+		header = append(header, c[0])
+	}
+	return "", 0, fmt.Errorf("object %s: malformed header", h)
+}
+
+// readLoose reads a zlib-compressed "<type> <size>\x00<data>" object file
+func (s *objectStore) readLoose(h Hash) (string, []byte, error) {
+	hexID := h.String()
+	f, err := os.Open(filepath.Join(s.dir, hexID[:2], hexID[2:]))
+	if os.IsNotExist(err) {
+		return "", nil, fmt.Errorf("%s: %w", h, ErrObjectNotFound)
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	defer f.Close()
+
+	zr, err := zlib.NewReader(f)
+	if err != nil {
+		return "", nil, fmt.Errorf("object %s: %w", h, err)
+	}
+	defer zr.Close()
+
+	raw, err := io.ReadAll(zr)
+	if err != nil {
+		return "", nil, fmt.Errorf("object %s: %w", h, err)
+	}
+
+	header, data, ok := bytes.Cut(raw, []byte{0})
+	if !ok {
+		return "", nil, fmt.Errorf("object %s: malformed header", h)
+	}
+	typ, sizeStr, ok := strings.Cut(string(header), " ")
+	if !ok {
+		return "", nil, fmt.Errorf("object %s: malformed header", h)
+	}
+	size, err := strconv.Atoi(sizeStr)
+	if err != nil || size != len(data) {
+		return "", nil, fmt.Errorf("object %s: size mismatch", h)
+	}
+	return typ, data, nil
+}
+
+// resolvePrefix finds the unique object whose hex id starts with prefix
+func (s *objectStore) resolvePrefix(prefix string) ([]Hash, error) {
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+
+	seen := make(map[Hash]bool)
+
+	entries, err := os.ReadDir(filepath.Join(s.dir, prefix[:2]))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, e := range entries {
+		full := prefix[:2] + e.Name()
+		if strings.HasPrefix(full, prefix) {
+			if h, err := ParseHash(full); err == nil {
+				seen[h] = true
+			}
+		}
+	}
+
+	for _, p := range s.packs {
+		for _, h := range p.withPrefix(prefix) {
+			seen[h] = true
+		}
+	}
+
+	for _, alt := range s.alternates {
+		hs, err := alt.resolvePrefix(prefix)
+		if err != nil {
+			return nil, err
+		}
+		for _, h := range hs {
+			seen[h] = true
+		}
+	}
+
+	var matches []Hash
+	for h := range seen {
+		matches = append(matches, h)
+	}
+	return matches, nil
+}
+
+func (s *objectStore) close() error {
+	var firstErr error
+	for _, p := range s.packs {
+		if err := p.file.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	for _, alt := range s.alternates {
+		if err := alt.close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Pack object types
+const (
+	packCommit   = 1
+	packTree     = 2
+	packBlob     = 3
+	packTag      = 4
+	packOfsDelta = 6
+	packRefDelta = 7
+)
+
+// maxDeltaDepth bounds delta chains, guarding against corrupt packs
+const maxDeltaDepth = 1000
+
+var packTypeNames = map[int]string{
+	packCommit: TypeCommit,
+	packTree:   TypeTree,
+	packBlob:   TypeBlob,
+	packTag:    TypeTag,
+}
+
+// pack is a packfile with its version 2 index loaded into memory
+type pack struct {
+	file    *os.File
+	hashes  []Hash
+	offsets []int64
+	fanout  [256]uint32
+}
+
+// openPack loads base+".idx" and opens base+".pack"
+func openPack(base string) (*pack, error) {
+	idx, err := os.ReadFile(base + ".idx")
+	if err != nil {
+		return nil, err
+	}
+	p, err := parseIndex(idx)
+	if err != nil {
+		return nil, fmt.Errorf("%s.idx: %w", base, err)
+	}
+
+	p.file, err = os.Open(base + ".pack")
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// parseIndex parses a version 2 pack index: magic, version, fanout table,
+// sorted object ids, CRCs, 32-bit offsets and optional 64-bit offsets
+func parseIndex(idx []byte) (*pack, error) {
+	const headerLen = 8 + 256*4
+	if len(idx) < headerLen || !bytes.Equal(idx[:4], []byte{0xff, 't', 'O', 'c'}) {
+		return nil, errors.New("unsupported pack index version")
+	}
+	if binary.BigEndian.Uint32(idx[4:8]) != 2 {
+		return nil, errors.New("unsupported pack index version")
+	}
+
+	p := &pack{}
+	for i := range p.fanout {
+		p.fanout[i] = binary.BigEndian.Uint32(idx[8+i*4:])
+	}
+	n := int(p.fanout[255])
+
+	hashStart := headerLen
+	crcStart := hashStart + n*20
+	offStart := crcStart + n*4
+	largeStart := offStart + n*4
+	if len(idx) < largeStart {
+		return nil, errors.New("truncated pack index")
+	}
+
+	p.hashes = make([]Hash, n)
+	p.offsets = make([]int64, n)
+	for i := 0; i < n; i++ {
+		copy(p.hashes[i][:], idx[hashStart+i*20:])
+
+		off := binary.BigEndian.Uint32(idx[offStart+i*4:])
+		if off&0x80000000 == 0 {
+			p.offsets[i] = int64(off)
+			continue
+		}
+		pos := largeStart + int(off&0x7fffffff)*8
+		if len(idx) < pos+8 {
+			return nil, errors.New("truncated pack index")
+		}
+		p.offsets[i] = int64(binary.BigEndian.Uint64(idx[pos:]))
+	}
+	return p, nil
+}
+
+// bucket returns the index range of ids starting with first byte b
+func (p *pack) bucket(b byte) (int, int) {
+	lo := 0
+	if b > 0 {
+		lo = int(p.fanout[b-1])
+	}
+	return lo, int(p.fanout[b])
+}
+
+func (p *pack) find(h Hash) (int64, bool) {
+	lo, hi := p.bucket(h[0])
+	i := lo + sort.Search(hi-lo, func(i int) bool {
+		return bytes.Compare(p.hashes[lo+i][:], h[:]) >= 0
+	})
+	if i < hi && p.hashes[i] == h {
+		return p.offsets[i], true
+	}
+	return 0, false
+}
+
+func (p *pack) withPrefix(prefix string) []Hash {
+	first, err := strconv.ParseUint(prefix[:2], 16, 8)
+	if err != nil {
+		return nil
+	}
+	lo, hi := p.bucket(byte(first))
+
+	var matches []Hash
+	for _, h := range p.hashes[lo:hi] {
+		if strings.HasPrefix(h.String(), prefix) {
+			matches = append(matches, h)
+		}
+	}
+	return matches
+}
+
+// readAt reads and, for deltas, reconstructs the object at offset. Bases
+// of reference deltas are looked up through the store.
+func (p *pack) readAt(offset int64, store *objectStore) (string, []byte, error) {
+	return p.readDepth(offset, store, 0)
+}
+
+// packEntry is the header of a pack entry
+type packEntry struct {
+	typ      int
+	size     int64 // Inflated size; for deltas the size of the delta
+	baseOff  int64 // Base offset of an offset delta
+	baseHash Hash  // Base of a reference delta
+	data     *byteReader
+}
+
+// entry reads the header of the entry at offset, leaving data positioned
+// at its compressed content
+func (p *pack) entry(offset int64) (*packEntry, error) {
+	br := &byteReader{r: &offsetReader{r: p.file, off: offset}}
+	e := &packEntry{data: br}
+
+	// Type and size: 3 type bits and 4 size bits, then 7 size bits per byte
+	c, err := br.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	e.typ = int(c>>4) & 7
+	e.size = int64(c & 0x0f)
+	shift := 4
+	for c&0x80 != 0 {
+		if c, err = br.ReadByte(); err != nil {
+			return nil, err
+		}
+		e.size |= int64(c&0x7f) << shift
+		shift += 7
+	}
+
+	switch e.typ {
+	case packOfsDelta:
+		// Big-endian base-128 with an offset of one added per continuation
+		if c, err = br.ReadByte(); err != nil {
+			return nil, err
+		}
+		rel := int64(c & 0x7f)
+		for c&0x80 != 0 {
+			if c, err = br.ReadByte(); err != nil {
+				return nil, err
+			}
+			rel = ((rel + 1) << 7) | int64(c&0x7f)
+		}
+		e.baseOff = offset - rel
+	case packRefDelta:
+		if _, err = io.ReadFull(br, e.baseHash[:]); err != nil {
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+func (p *pack) readDepth(offset int64, store *objectStore, depth int) (string, []byte, error) {
+	if depth > maxDeltaDepth {
+		return "", nil, errors.New("pack delta chain too deep")
+	}
+
+	e, err := p.entry(offset)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var baseType string
+	var base []byte
+	switch e.typ {
+	case packOfsDelta:
+		baseType, base, err = p.readDepth(e.baseOff, store, depth+1)
+	case packRefDelta:
+		if baseOff, ok := p.find(e.baseHash); ok {
+			baseType, base, err = p.readDepth(baseOff, store, depth+1)
+		} else {
+			baseType, base, err = store.read(e.baseHash)
+		}
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	zr, err := zlib.NewReader(e.data)
+	if err != nil {
+		return "", nil, fmt.Errorf("pack object at %d: %w", offset, err)
+	}
+	defer zr.Close()
+
+	data := make([]byte, e.size)
+	if _, err := io.ReadFull(zr, data); err != nil {
+		return "", nil, fmt.Errorf("pack object at %d: %w", offset, err)
+	}
+
+	if base != nil {
+		data, err = applyDelta(base, data)
+		if err != nil {
+			return "", nil, fmt.Errorf("pack object at %d: %w", offset, err)
+		}
+		return baseType, data, nil
+	}
+
+	name, ok := packTypeNames[e.typ]
+	if !ok {
+		return "", nil, fmt.Errorf("pack object at %d: unknown type %d", offset, e.typ)
+	}
+	return name, data, nil
+}
+
+// headerAt returns the type and size of the object at offset. For deltas,
+// the size is read from the start of the delta and the type from the end
+// of the base chain; neither content is reconstructed.
+func (p *pack) headerAt(offset int64, store *objectStore, depth int) (string, int64, error) {
+	if depth > maxDeltaDepth {
+		return "", 0, errors.New("pack delta chain too deep")
+	}
+
+	e, err := p.entry(offset)
+	if err != nil {
+		return "", 0, err
+	}
+
+	if e.typ != packOfsDelta && e.typ != packRefDelta {
+		name, ok := packTypeNames[e.typ]
+		if !ok {
+			return "", 0, fmt.Errorf("pack object at %d: unknown type %d", offset, e.typ)
+		}
+		return name, e.size, nil
+	}
+
+	// The delta starts with the source and target sizes
+	zr, err := zlib.NewReader(e.data)
+	if err != nil {
+		return "", 0, fmt.Errorf("pack object at %d: %w", offset, err)
+	}
+	defer zr.Close()
+	var size int64
+	for i := 0; i < 2; i++ {
+		size = 0
+		shift := 0
+		for {
+			var c [1]byte
+			if _, err := io.ReadFull(zr, c[:]); err != nil {
+				return "", 0, fmt.Errorf("pack object at %d: %w", offset, err)
+			}
+			size |= int64(c[0]&0x7f) << shift
+			shift += 7
+			if c[0]&0x80 == 0 {
+				break
+			}
+		}
+	}
+
+	var typ string
+	if e.typ == packOfsDelta {
+		typ, _, err = p.headerAt(e.baseOff, store, depth+1)
+	} else if baseOff, ok := p.find(e.baseHash); ok {
+		typ, _, err = p.headerAt(baseOff, store, depth+1)
+	} else {
+		typ, _, err = store.header(e.baseHash)
+	}
+	if err != nil {
+		return "", 0, err
+	}
+	return typ, size, nil
+}
+
+// applyDelta applies a git delta: source and target sizes, followed by
+// copy (high bit set) and insert instructions
+func applyDelta(base, delta []byte) ([]byte, error) {
+	pos := 0
+	readSize := func() (int, error) {
+		size, shift := 0, 0
+		for {
+			if pos >= len(delta) {
+				return 0, errors.New("truncated delta")
+			}
+			c := delta[pos]
+			pos++
+			size |= int(c&0x7f) << shift
+			shift += 7
+			if c&0x80 == 0 {
+				return size, nil
+			}
+		}
+	}
+
+	srcSize, err := readSize()
+	if err != nil {
+		return nil, err
+	}
+	if srcSize != len(base) {
+		return nil, errors.New("delta base size mismatch")
+	}
+	dstSize, err := readSize()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, dstSize)
+	for pos < len(delta) {
+		op := delta[pos]
+		pos++
+
+		if op&0x80 == 0 {
+			// Insert the next op bytes literally
+			n := int(op)
+			if n == 0 || pos+n > len(delta) {
+				return nil, errors.New("invalid delta insert")
+			}
+			out = append(out, delta[pos:pos+n]...)
+			pos += n
+			continue
+		}
+
+		// Copy: bits 0-3 select offset bytes, bits 4-6 select size bytes
+		var offset, size int
+		for i := 0; i < 4; i++ {
+			if op&(1<<i) != 0 {
+				if pos >= len(delta) {
+					return nil, errors.New("truncated delta")
+				}
+				offset |= int(delta[pos]) << (8 * i)
+				pos++
+			}
+		}
+		for i := 0; i < 3; i++ {
+			if op&(1<<(4+i)) != 0 {
+				if pos >= len(delta) {
+					return nil, errors.New("truncated delta")
+				}
+				size |= int(delta[pos]) << (8 * i)
+				pos++
+			}
+		}
+		if size == 0 {
+			size = 0x10000
+		}
+		if offset+size > len(base) {
+			return nil, errors.New("invalid delta copy")
+		}
+		out = append(out, base[offset:offset+size]...)
+	}
+
+	if len(out) != dstSize {
+		return nil, errors.New("delta result size mismatch")
+	}
+	return out, nil
+}
+
+// offsetReader reads sequentially from an io.ReaderAt
+type offsetReader struct {
+	r   io.ReaderAt
+	off int64
+}
+
+func (o *offsetReader) Read(p []byte) (int, error) {
+	n, err := o.r.ReadAt(p, o.off)
+	o.off += int64(n)
+	if n > 0 && err == io.EOF {
+		err = nil
+	}
+	return n, err
+}
+
+// byteReader adds io.ByteReader to a reader so zlib does not read past
+// the end of the compressed stream
+type byteReader struct {
+	r   io.Reader
+	buf [4096]byte
+	pos int
+	n   int
+}
+
+func (b *byteReader) fill() error {
+	if b.pos < b.n {
+		return nil
+	}
+	n, err := b.r.Read(b.buf[:])
+	if n == 0 {
+		if err == nil {
+			err = io.ErrNoProgress
+		}
+		return err
+	}
+	b.pos, b.n = 0, n
+	return nil
+}
+
+func (b *byteReader) ReadByte() (byte, error) {
+	if err := b.fill(); err != nil {
+		return 0, err
+	}
+	c := b.buf[b.pos]
+	b.pos++
+	return c, nil
+}
+
+func (b *byteReader) Read(p []byte) (int, error) {
+	if err := b.fill(); err != nil {
+		return 0, err
+	}
+	n := copy(p, b.buf[b.pos:b.n])
+	b.pos += n
+	return n, nil
+}
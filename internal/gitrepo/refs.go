@@ -0,0 +1,165 @@
+package gitrepo
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Ref is a named reference resolved to an object id
+type Ref struct {
+	Name string // Full name, e.g. "refs/heads/main"
+	Hash Hash
+}
+
+// maxSymrefDepth bounds symbolic ref chains
+const maxSymrefDepth = 5
+
+// Head returns the commit HEAD points to and the branch it names, if any
+func (r *Repo) Head() (Hash, string, error) {
+	target, err := r.readRefFile(r.GitDir, "HEAD")
+	if err != nil {
+		return Hash{}, "", err
+	}
+
+	branch := ""
+	if name, ok := strings.CutPrefix(target, "ref: "); ok {
+		branch = name
+	}
+
+	h, err := r.ResolveRef("HEAD")
+	return h, branch, err
+}
+
+// ResolveRef resolves a full ref name (or HEAD), following symbolic refs
+func (r *Repo) ResolveRef(name string) (Hash, error) {
+	for depth := 0; depth < maxSymrefDepth; depth++ {
+		// Pseudo-refs like HEAD are per worktree
+		dir := r.CommonDir
+		if !strings.HasPrefix(name, "refs/") {
+			dir = r.GitDir
+		}
+
+		target, err := r.readRefFile(dir, name)
+		if os.IsNotExist(err) {
+			target, err = r.packedRef(name)
+		}
+		if err != nil {
+			return Hash{}, err
+		}
+
+		if next, ok := strings.CutPrefix(target, "ref: "); ok {
+			name = next
+			continue
+		}
+		return ParseHash(target)
+	}
+
+	return Hash{}, fmt.Errorf("ref %s: too many levels of symbolic refs", name)
+}
+
+func (r *Repo) readRefFile(dir, name string) (string, error) {
+	path := filepath.Join(dir, filepath.FromSlash(name))
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		return "", os.ErrNotExist
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// packedRef looks up name in packed-refs
+func (r *Repo) packedRef(name string) (string, error) {
+	refs, err := r.packedRefs()
+	if err != nil {
+		return "", err
+	}
+	if h, ok := refs[name]; ok {
+		return h, nil
+	}
+	return "", fmt.Errorf("ref %s: %w", name, os.ErrNotExist)
+}
+
+// packedRefs parses packed-refs into name -> hash
+func (r *Repo) packedRefs() (map[string]string, error) {
+	refs := make(map[string]string)
+
+	f, err := os.Open(filepath.Join(r.CommonDir, "packed-refs"))
+	if os.IsNotExist(err) {
+		return refs, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		// Skip the header and peeled tag lines
+		if line == "" || line[0] == '#' || line[0] == '^' {
+			continue
+		}
+		hash, name, ok := strings.Cut(line, " ")
+		if ok {
+			refs[name] = hash
+		}
+	}
+	return refs, scanner.Err()
+}
+
+// Refs lists all branches, remote branches and tags, sorted by name.
+// Loose refs take precedence over packed refs.
+func (r *Repo) Refs() ([]Ref, error) {
+	names := make(map[string]bool)
+
+	packed, err := r.packedRefs()
+	if err != nil {
+		return nil, err
+	}
+	for name := range packed {
+		names[name] = true
+	}
+
+	refsDir := filepath.Join(r.CommonDir, "refs")
+	err = filepath.WalkDir(refsDir, func(path string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			if os.IsNotExist(walkErr) {
+				return nil
+			}
+			return walkErr
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(r.CommonDir, path)
+		if err != nil {
+			return err
+		}
+		names[filepath.ToSlash(rel)] = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var refs []Ref
+	for name := range names {
+		h, err := r.ResolveRef(name)
+		if err != nil {
+			continue // dangling or malformed refs are skipped, as git log does
+		}
+		refs = append(refs, Ref{Name: name, Hash: h})
+	}
+
+	sort.Slice(refs, func(i, j int) bool {
+		return refs[i].Name < refs[j].Name
+	})
+	return refs, nil
+}
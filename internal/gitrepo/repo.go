@@ -0,0 +1,140 @@
+// Package gitrepo reads git repositories natively, without the git binary.
+//
+// It supports repository discovery (including ".git" files with a
+// "gitdir:" pointer as used by worktrees and submodules), loose and packed
+// refs, loose objects, and packfiles with offset and reference deltas.
+// Only SHA-1 repositories are supported.
+package gitrepo
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrNotRepository is returned when no repository is found
+var ErrNotRepository = errors.New("not a git repository")
+
+// Repo is an opened git repository
+type Repo struct {
+	WorkTree  string // Top-level working tree directory
+	GitDir    string // Per-worktree git directory (HEAD, index)
+	CommonDir string // Shared git directory (objects, refs)
+
+	objects *objectStore
+}
+
+// Discover finds the repository containing dir by walking up from dir and
+// looking for a ".git" directory or a ".git" file with a "gitdir:" line
+func Discover(dir string) (*Repo, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		gitDir, ok, err := findGitDir(abs)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return Open(abs, gitDir)
+		}
+
+		parent := filepath.Dir(abs)
+		if parent == abs {
+			return nil, ErrNotRepository
+		}
+		abs = parent
+	}
+}
+
+// findGitDir checks dir for a ".git" directory or "gitdir:" file
+func findGitDir(dir string) (string, bool, error) {
+	dotGit := filepath.Join(dir, ".git")
+	info, err := os.Stat(dotGit)
+	if err != nil {
+		return "", false, nil
+	}
+
+	if info.IsDir() {
+		return dotGit, true, nil
+	}
+
+	gitDir, err := ReadGitFile(dotGit)
+	if err != nil {
+		return "", false, err
+	}
+	return gitDir, true, nil
+}
+
+// ReadGitFile resolves a ".git" file containing "gitdir: <path>"; relative
+// paths are resolved against the file's directory
+func ReadGitFile(path string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	line := strings.TrimSpace(string(b))
+	target, ok := strings.CutPrefix(line, "gitdir:")
+	if !ok {
+		return "", fmt.Errorf("%s: missing gitdir line", path)
+	}
+	target = strings.TrimSpace(target)
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(filepath.Dir(path), target)
+	}
+	return filepath.Clean(target), nil
+}
+
+// Open opens the repository with the given working tree and git directory
+func Open(workTree, gitDir string) (*Repo, error) {
+	if _, err := os.Stat(filepath.Join(gitDir, "HEAD")); err != nil {
+		return nil, fmt.Errorf("%s: %w", gitDir, ErrNotRepository)
+	}
+
+	// Linked worktrees share objects and refs through "commondir"
+	commonDir := gitDir
+	if b, err := os.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		common := strings.TrimSpace(string(b))
+		if !filepath.IsAbs(common) {
+			common = filepath.Join(gitDir, common)
+		}
+		commonDir = filepath.Clean(common)
+	}
+
+	return &Repo{
+		WorkTree:  workTree,
+		GitDir:    gitDir,
+		CommonDir: commonDir,
+		objects:   newObjectStore(filepath.Join(commonDir, "objects")),
+	}, nil
+}
+
+// Prefix returns the path of dir relative to the working tree, with
+// forward slashes and no leading "./" ("" for the top level)
+func (r *Repo) Prefix(dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(r.WorkTree, abs)
+	if err != nil {
+		return "", err
+	}
+	if rel == "." {
+		return "", nil
+	}
+	if strings.HasPrefix(rel, "..") {
+		return "", fmt.Errorf("%s is outside the working tree %s", dir, r.WorkTree)
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// Close releases open pack files
+func (r *Repo) Close() error {
+	return r.objects.close()
+}
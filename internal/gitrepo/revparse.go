@@ -0,0 +1,144 @@
+package gitrepo
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// minAbbrev is the shortest accepted abbreviated object id
+const minAbbrev = 4
+
+// refSearchPath lists the ref locations tried for a short name, in git's
+// documented order
+var refSearchPath = []string{
+	"%s",
+	"refs/%s",
+	"refs/tags/%s",
+	"refs/heads/%s",
+	"refs/remotes/%s",
+	"refs/remotes/%s/HEAD",
+}
+
+// ResolveRevision resolves a revision expression to a commit. Supported
+// forms are full or abbreviated object ids, ref names, and any number of
+// "~<n>" and "^<n>" suffixes, optionally ending in "^{commit}".
+func (r *Repo) ResolveRevision(rev string) (*Commit, error) {
+	rev = strings.TrimSuffix(rev, "^{commit}")
+
+	base := rev
+	if i := strings.IndexAny(rev, "~^"); i >= 0 {
+		base = rev[:i]
+	}
+	if base == "" || strings.HasPrefix(base, "-") {
+		return nil, fmt.Errorf("invalid revision %q", rev)
+	}
+
+	h, err := r.resolveName(base)
+	if err != nil {
+		return nil, err
+	}
+	c, err := r.ReadCommit(h)
+	if err != nil {
+		return nil, err
+	}
+
+	suffix := rev[len(base):]
+	for suffix != "" {
+		op := suffix[0]
+		suffix = suffix[1:]
+
+		digits := 0
+		for digits < len(suffix) && suffix[digits] >= '0' && suffix[digits] <= '9' {
+			digits++
+		}
+		n := 1
+		if digits > 0 {
+			n, _ = strconv.Atoi(suffix[:digits])
+		}
+		suffix = suffix[digits:]
+
+		switch op {
+		case '~':
+			for i := 0; i < n; i++ {
+				if c, err = r.parent(c, 1, rev); err != nil {
+					return nil, err
+				}
+			}
+		case '^':
+			if n == 0 {
+				continue
+			}
+			if c, err = r.parent(c, n, rev); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("invalid revision %q", rev)
+		}
+	}
+
+	return c, nil
+}
+
+func (r *Repo) parent(c *Commit, n int, rev string) (*Commit, error) {
+	if n > len(c.Parents) {
+		return nil, fmt.Errorf("revision %q: commit %s has no parent %d", rev, c.Hash.Short(7), n)
+	}
+	return r.ReadCommit(c.Parents[n-1])
+}
+
+// resolveName resolves a ref name or (abbreviated) object id
+func (r *Repo) resolveName(name string) (Hash, error) {
+	for _, pattern := range refSearchPath {
+		if pattern == "%s" && !isPseudoRef(name) {
+			continue
+		}
+		h, err := r.ResolveRef(fmt.Sprintf(pattern, name))
+		if err == nil {
+			return h, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return Hash{}, err
+		}
+	}
+
+	if len(name) >= minAbbrev && isHex(name) {
+		if h, err := ParseHash(name); err == nil {
+			return h, nil
+		}
+		matches, err := r.objects.resolvePrefix(strings.ToLower(name))
+		if err != nil {
+			return Hash{}, err
+		}
+		switch len(matches) {
+		case 1:
+			return matches[0], nil
+		case 0:
+		default:
+			return Hash{}, fmt.Errorf("short object id %s is ambiguous", name)
+		}
+	}
+
+	return Hash{}, fmt.Errorf("unknown revision %q", name)
+}
+
+// isPseudoRef reports whether name looks like HEAD, ORIG_HEAD etc.
+func isPseudoRef(name string) bool {
+	for _, c := range name {
+		if (c < 'A' || c > 'Z') && c != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+func isHex(s string) bool {
+	for _, c := range s {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+			return false
+		}
+	}
+	return true
+}
@@ -14,7 +14,7 @@ import (
 // DefaultPatterns mirrors the shell script's default excludes.
 var DefaultPatterns = []string{
 	// VCS and dependencies
-	".git/",
+	".git", // directory, or gitdir file in submodules and worktrees
 	"node_modules/",
 	".venv/",
 	"venv/",
@@ -31,6 +31,7 @@ var DefaultPatterns = []string{
 
 	// Snapshot files themselves
 	"**/*.snp",
+	"**/*.snp.gz",
 }
 
 // Matchers holds compiled ignore and include patterns with proper precedence.
@@ -45,6 +46,9 @@ type Matchers struct {
 	exclude     *gitignore.GitIgnore // CLI --exclude (final)
 	hasIncludes bool
 	hasExcludes bool
+
+	// prefix is the nested repository root baseIgnore is relative to
+	prefix string
 }
 
 // NewMatchers builds ignore/include matchers from defaults, .gitignore,
@@ -64,13 +68,24 @@ func NewMatchers(sourceDir string, excludePatterns, includePatterns []string) (*
 		return nil, fmt.Errorf("%q is not a directory", absSourceDir)
 	}
 
+	var gitignoreContent []byte
+	gitignorePath := filepath.Join(absSourceDir, ".gitignore")
+	if b, err := os.ReadFile(gitignorePath); err == nil {
+		gitignoreContent = b
+	}
+
+	return NewMatchersFromGitignore(gitignoreContent, excludePatterns, includePatterns), nil
+}
+
+// NewMatchersFromGitignore builds matchers like NewMatchers, taking the
+// .gitignore content directly instead of reading it from disk (nil if absent).
+func NewMatchersFromGitignore(gitignoreContent []byte, excludePatterns, includePatterns []string) *Matchers {
 	// Base ignore: defaults + .gitignore
 	var baseIgnoreLines []string
 	baseIgnoreLines = append(baseIgnoreLines, DefaultPatterns...)
 
-	gitignorePath := filepath.Join(absSourceDir, ".gitignore")
-	if b, err := os.ReadFile(gitignorePath); err == nil {
-		lines := strings.Split(string(b), "\n")
+	if gitignoreContent != nil {
+		lines := strings.Split(string(gitignoreContent), "\n")
 		baseIgnoreLines = append(baseIgnoreLines, lines...)
 	}
 
@@ -97,7 +112,24 @@ func NewMatchers(sourceDir string, excludePatterns, includePatterns []string) (*
 		exclude:     excludeMatcher,
 		hasIncludes: len(includePatterns) > 0,
 		hasExcludes: len(excludePatterns) > 0,
-	}, nil
+	}
+}
+
+// Nested returns matchers for the nested repository (e.g. a submodule) at
+// relPrefix, whose directory is dir. Defaults and the nested repository's
+// own .gitignore replace the outer .gitignore below it; CLI patterns still
+// match paths relative to the snapshot root.
+func (m *Matchers) Nested(relPrefix, dir string) (*Matchers, error) {
+	content, err := os.ReadFile(filepath.Join(dir, ".gitignore"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	nested := NewMatchersFromGitignore(content, nil, nil)
+	nested.include, nested.hasIncludes = m.include, m.hasIncludes
+	nested.exclude, nested.hasExcludes = m.exclude, m.hasExcludes
+	nested.prefix = relPrefix
+	return nested, nil
 }
 
 // ShouldInclude decides if a relative path should be included in the snapshot.
@@ -125,10 +157,16 @@ func (m *Matchers) ShouldInclude(relPath string) bool {
 	}
 
 	// Step 3: Check base ignore (defaults + .gitignore)
-	if m.baseIgnore != nil && m.baseIgnore.MatchesPath(relPath) {
+	if m.baseIgnore != nil && m.baseIgnore.MatchesPath(strings.TrimPrefix(relPath, m.prefix+"/")) {
 		return false
 	}
 
 	// Step 4: Default to include
 	return true
 }
+
+// SkipsDir reports whether nothing below the directory relDir can be
+// included: it is ignored and no --include pattern could rescue its files
+func (m *Matchers) SkipsDir(relDir string) bool {
+	return m != nil && !m.hasIncludes && !m.ShouldInclude(relDir+"/")
+}
@@ -193,3 +193,43 @@ func TestNewMatchers_InvalidDirectory(t *testing.T) {
 		t.Error("NewMatchers should fail for nonexistent directory")
 	}
 }
+
+func TestNested(t *testing.T) {
+	tmpDir := t.TempDir()
+	subDir := filepath.Join(tmpDir, "deps", "lib")
+	if err := os.MkdirAll(subDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, ".gitignore"), []byte("*.gen\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(subDir, ".gitignore"), []byte("/out/\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	outer, err := ignore.NewMatchers(tmpDir, []string{"deps/lib/secret.txt"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nested, err := outer.Nested("deps/lib", subDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{path: "deps/lib/code.gen", want: true},    // outer .gitignore does not apply
+		{path: "deps/lib/out/a.go", want: false},   // nested .gitignore, anchored at its root
+		{path: "deps/lib/.git", want: false},       // gitdir file
+		{path: "deps/lib/secret.txt", want: false}, // CLI excludes still apply
+		{path: "deps/lib/src/main.go", want: true},
+	}
+
+	for _, tt := range tests {
+		if got := nested.ShouldInclude(tt.path); got != tt.want {
+			t.Errorf("ShouldInclude(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
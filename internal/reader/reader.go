@@ -0,0 +1,289 @@
+// Package reader parses snapshot files written by the snapshot package.
+package reader
+
+import (
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// separator is the line between preamble sections
+const separator = "# ----------------------------------------"
+
+// indexHeader is the header of the file index section
+const indexHeader = "# File Index"
+
+// ErrSplitPart is returned for a part of a split snapshot, which does not
+// hold the content of every indexed file
+var ErrSplitPart = errors.New("snapshot is one part of a split snapshot")
+
+// indexEntry matches "path [start-end] (attrs)"
+var indexEntry = regexp.MustCompile(`^(.*) \[(\d+)-(\d+)\] \((.*)\)$`)
+
+// partHeader matches the first line of a split snapshot part
+var partHeader = regexp.MustCompile(`^# Part \d+ of \d+$`)
+
+// Snapshot is a parsed snapshot file
+type Snapshot struct {
+	Summary   []string          // Summary lines before the file index
+	Fields    map[string]string // "Key: value" summary fields, e.g. "Generated"
+	Sections  []Section         // Preamble sections, starting with the file index
+	Files     []*File           // Indexed files in index order
+	Collapsed []string          // Index entries without content, e.g. submodules
+	Lines     []string          // All lines of the snapshot
+
+	indexStart int // Position of the file index header in Lines
+}
+
+// Section is a preamble section such as the git log
+type Section struct {
+	Title string // Header without "# "
+	Lines []string
+}
+
+// File is an indexed file with its content
+type File struct {
+	RelPath   string
+	StartLine int      // First content line (1-based)
+	EndLine   int      // Last content line
+	Attrs     []string // Index attributes, e.g. "12 lines", "3.8 KB"
+	Binary    bool
+	Size      string // Human-readable size as shown in the index
+	Hash      string // Hex SHA-256 of the source file (snapshots created with --hashes)
+	Lines     []string
+}
+
+// ReadFile parses the snapshot at path
+func ReadFile(path string) (*Snapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	snap, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return snap, nil
+}
+
+// Parse parses a snapshot, which may be gzip-compressed. File contents are located through the line
+// ranges of the file index, so content resembling headers is harmless.
+func Parse(r io.Reader) (*Snapshot, error) {
+	r, err := decompress(r)
+	if err != nil {
+		return nil, err
+	}
+	lines, err := readLines(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(lines) > 0 && partHeader.MatchString(lines[0]) {
+		return nil, ErrSplitPart
+	}
+
+	snap := &Snapshot{Fields: make(map[string]string), Lines: lines}
+
+	indexStart := -1
+	for i, line := range lines {
+		if line == indexHeader {
+			indexStart = i
+			break
+		}
+	}
+	if indexStart < 0 {
+		return nil, errors.New("not a snapshot: missing file index")
+	}
+
+	snap.indexStart = indexStart
+	snap.Summary = trimBlank(lines[:indexStart])
+	for _, line := range snap.Summary {
+		if key, value, ok := strings.Cut(line, ": "); ok && !strings.HasPrefix(line, " ") {
+			if _, seen := snap.Fields[key]; !seen {
+				snap.Fields[key] = value
+			}
+		}
+	}
+
+	// Index entries run up to the first blank line
+	end := indexStart + 1
+	for ; end < len(lines) && lines[end] != ""; end++ {
+		f, ok, err := parseEntry(lines[end], len(lines))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", end+1, err)
+		}
+		if !ok {
+			snap.Collapsed = append(snap.Collapsed, lines[end])
+			continue
+		}
+		f.Lines = lines[f.StartLine-1 : f.EndLine]
+		snap.Files = append(snap.Files, f)
+	}
+
+	// Preamble sections end at the first file header
+	preambleEnd := len(lines)
+	for _, f := range snap.Files {
+		preambleEnd = min(preambleEnd, f.StartLine-2)
+	}
+	snap.Sections = parseSections(lines[indexStart:max(preambleEnd, indexStart)])
+
+	return snap, nil
+}
+
+// parseEntry parses an index entry. Entries without a line range are
+// reported as not ok.
+func parseEntry(line string, total int) (*File, bool, error) {
+	m := indexEntry.FindStringSubmatch(line)
+	if m == nil {
+		return nil, false, nil
+	}
+
+	start, err1 := strconv.Atoi(m[2])
+	end, err2 := strconv.Atoi(m[3])
+	if err1 != nil || err2 != nil || start < 2 || end < start-1 || end > total {
+		return nil, false, fmt.Errorf("invalid line range in index entry %q", line)
+	}
+
+	f := &File{
+		RelPath:   m[1],
+		StartLine: start,
+		EndLine:   end,
+		Attrs:     strings.Split(m[4], ", "),
+	}
+	for i, attr := range f.Attrs {
+		if attr == "binary" {
+			f.Binary = true
+			if i+1 < len(f.Attrs) {
+				f.Size = f.Attrs[i+1]
+			}
+		}
+		if strings.HasSuffix(attr, " lines") && i+1 < len(f.Attrs) {
+			f.Size = f.Attrs[i+1]
+		}
+		if hash, ok := strings.CutPrefix(attr, "sha256:"); ok {
+			f.Hash = hash
+		}
+	}
+	return f, true, nil
+}
+
+// parseSections splits preamble lines at separators into titled sections
+func parseSections(lines []string) []Section {
+	var sections []Section
+	var current []string
+
+	flush := func() {
+		block := trimBlank(current)
+		current = nil
+		if len(block) == 0 || !strings.HasPrefix(block[0], "# ") {
+			return
+		}
+		sections = append(sections, Section{Title: block[0][2:], Lines: block[1:]})
+	}
+
+	for _, line := range lines {
+		if line == separator {
+			flush()
+			continue
+		}
+		current = append(current, line)
+	}
+	flush()
+
+	return sections
+}
+
+// Digest returns the hex SHA-256 of every line from the file index header
+// to the end, to compare with the recorded "Digest" summary field
+func (s *Snapshot) Digest() string {
+	h := sha256.New()
+	for _, line := range s.Lines[s.indexStart:] {
+		h.Write([]byte(line))
+		h.Write([]byte{'\n'})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Section returns the preamble section with the given title prefix, or nil
+func (s *Snapshot) Section(titlePrefix string) *Section {
+	for i := range s.Sections {
+		if strings.HasPrefix(s.Sections[i].Title, titlePrefix) {
+			return &s.Sections[i]
+		}
+	}
+	return nil
+}
+
+// File returns the indexed file with relPath, or nil
+func (s *Snapshot) File(relPath string) *File {
+	for _, f := range s.Files {
+		if f.RelPath == relPath {
+			return f
+		}
+	}
+	return nil
+}
+
+// ParseSize parses a size as formatted in the index ("154 bytes",
+// "3.8 KB"). Sizes of a kilobyte or more are approximate.
+func ParseSize(s string) (int64, bool) {
+	num, unit, ok := strings.Cut(s, " ")
+	if !ok {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	scale := map[string]float64{"byte": 1, "bytes": 1, "KB": 1 << 10, "MB": 1 << 20, "GB": 1 << 30}[unit]
+	if scale == 0 {
+		return 0, false
+	}
+	return int64(v*scale + 0.5), true
+}
+
+// decompress returns a reader of the uncompressed content of r, which is
+// read through gzip when it starts with the gzip magic bytes
+func decompress(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(2)
+	if err != nil || magic[0] != 0x1f || magic[1] != 0x8b {
+		return br, nil
+	}
+	zr, err := gzip.NewReader(br)
+	if err != nil {
+		return nil, fmt.Errorf("invalid gzip data: %w", err)
+	}
+	return zr, nil
+}
+
+func readLines(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines, scanner.Err()
+}
+
+// trimBlank removes leading and trailing empty lines
+func trimBlank(lines []string) []string {
+	for len(lines) > 0 && lines[0] == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
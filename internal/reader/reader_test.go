@@ -0,0 +1,114 @@
+package reader_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/neox5/snp/internal/reader"
+	"github.com/neox5/snp/internal/snapshot"
+)
+
+func TestParse_RoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"main.go":       "package main\n\nfunc main() {}\n",
+		"docs/fake.md":  "# File Index\nnot/an/entry [1-2] (1 lines)\n# ----------------------------------------\n",
+		"data/blob.bin": "\x00\x01\x02",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := snapshot.Config{SourceDir: dir, OutputPath: filepath.Join(dir, "out.snp"), IncludeTree: true, IncludeHashes: true}
+	snap, err := snapshot.Build(context.Background(), cfg, dir, cfg.OutputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err := snap.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := reader.Parse(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := parsed.Fields["Total files"]; got != "3 (2 text, 1 binary)" {
+		t.Errorf("Total files = %q", got)
+	}
+	if got, want := parsed.Fields["Digest"], "sha256:"+parsed.Digest(); got != want {
+		t.Errorf("Digest = %q, want %q", got, want)
+	}
+	if parsed.Section("Directory Tree") == nil {
+		t.Error("missing Directory Tree section")
+	}
+	if len(parsed.Files) != len(snap.Files) {
+		t.Fatalf("parsed %d files, want %d", len(parsed.Files), len(snap.Files))
+	}
+	for _, f := range snap.Files {
+		pf := parsed.File(f.RelPath)
+		if pf == nil {
+			t.Fatalf("missing %s", f.RelPath)
+		}
+		if !reflect.DeepEqual(pf.Lines, f.Lines) {
+			t.Errorf("%s: lines = %q, want %q", f.RelPath, pf.Lines, f.Lines)
+		}
+		if pf.Hash == "" || pf.Hash != f.Hash {
+			t.Errorf("%s: hash = %q, want %q", f.RelPath, pf.Hash, f.Hash)
+		}
+		if pf.Binary != f.IsBinary {
+			t.Errorf("%s: binary = %v, want %v", f.RelPath, pf.Binary, f.IsBinary)
+		}
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := map[string]int64{"0 bytes": 0, "1 byte": 1, "154 bytes": 154, "1.5 KB": 1536, "2.0 MB": 2 << 20}
+	for s, want := range tests {
+		if got, ok := reader.ParseSize(s); !ok || got != want {
+			t.Errorf("ParseSize(%q) = %d, %v, want %d", s, got, ok, want)
+		}
+	}
+}
+
+func TestParse_Gzip(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := snapshot.Config{SourceDir: dir, OutputPath: filepath.Join(dir, "out.snp"), Compress: true}
+	_, absOutput, err := snapshot.ValidateAndResolve(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Ext(absOutput) != ".gz" {
+		t.Fatalf("output path %q lacks .gz", absOutput)
+	}
+	snap, err := snapshot.Build(context.Background(), cfg, dir, absOutput)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := snapshot.WriteFile(absOutput, snap); err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := reader.ReadFile(absOutput)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f := parsed.File("main.go"); f == nil || !reflect.DeepEqual(f.Lines, []string{"package main"}) {
+		t.Errorf("main.go = %+v", f)
+	}
+}
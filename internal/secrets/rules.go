@@ -0,0 +1,124 @@
+package secrets
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	gitignore "github.com/sabhiram/go-gitignore"
+)
+
+// DefaultReplacement replaces matches of rules without a replacement
+const DefaultReplacement = "[REDACTED]"
+
+// Rule is a user-defined regex replacement, optionally limited to paths
+// matching gitignore-style globs
+type Rule struct {
+	Spec        string
+	Pattern     *regexp.Regexp
+	Replacement string // May refer to submatches as $1 or ${name}
+	Paths       []string
+
+	paths *gitignore.GitIgnore
+}
+
+// ParseRule parses a rule spec of the form
+//
+//	[@GLOB[,GLOB...]:]REGEX[=>REPLACEMENT]
+//
+// e.g. `[a-z]+\.corp\.example\.com=>host.example` or
+// `@docs/**,*.md:Acme Corp=>Customer`
+func ParseRule(spec string) (Rule, error) {
+	r := Rule{Spec: spec, Replacement: DefaultReplacement}
+
+	rest := spec
+	if strings.HasPrefix(rest, "@") {
+		scope, pattern, ok := strings.Cut(rest[1:], ":")
+		if !ok || scope == "" {
+			return Rule{}, fmt.Errorf("invalid redaction rule %q: expected @GLOB:REGEX", spec)
+		}
+		r.Paths = strings.Split(scope, ",")
+		r.paths = gitignore.CompileIgnoreLines(r.Paths...)
+		rest = pattern
+	}
+
+	pattern, replacement, ok := strings.Cut(rest, "=>")
+	if ok {
+		r.Replacement = replacement
+	}
+	if pattern == "" {
+		return Rule{}, fmt.Errorf("invalid redaction rule %q: empty pattern", spec)
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return Rule{}, fmt.Errorf("invalid redaction rule %q: %w", spec, err)
+	}
+	r.Pattern = re
+
+	return r, nil
+}
+
+// LoadRules reads one rule spec per line from path. Blank lines and lines
+// starting with # are skipped.
+func LoadRules(path string) ([]Rule, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read redaction rules: %w", err)
+	}
+
+	var rules []Rule
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		r, err := ParseRule(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, n, err)
+		}
+		rules = append(rules, r)
+	}
+	return rules, scanner.Err()
+}
+
+// Applies reports whether the rule covers relPath. Unscoped rules cover
+// everything, including lines without a path such as the git log.
+func (r Rule) Applies(relPath string) bool {
+	if r.paths == nil {
+		return true
+	}
+	return relPath != "" && r.paths.MatchesPath(relPath)
+}
+
+// ApplyRules replaces matches of the rules covering relPath in lines in
+// place and returns the number of replacements
+func ApplyRules(rules []Rule, relPath string, lines []string) int {
+	n := 0
+	for _, r := range rules {
+		if !r.Applies(relPath) {
+			continue
+		}
+		for i, line := range lines {
+			if matches := len(r.Pattern.FindAllStringIndex(line, -1)); matches > 0 {
+				n += matches
+				lines[i] = r.Pattern.ReplaceAllString(line, r.Replacement)
+			}
+		}
+	}
+	return n
+}
+
+// ApplyRulesDiff applies rules to a unified diff in place, choosing them by
+// the path of each file in the diff. Returns the number of replacements.
+func ApplyRulesDiff(rules []Rule, lines []string) int {
+	n := 0
+	for _, c := range diffChunks(lines) {
+		n += ApplyRules(rules, c.path, c.lines)
+	}
+	return n
+}
@@ -0,0 +1,297 @@
+// Package secrets detects credentials in file contents and applies
+// user-defined redaction rules before content reaches a snapshot.
+package secrets
+
+import (
+	"fmt"
+	"math"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Finding is one redacted secret
+type Finding struct {
+	Path        string
+	Line        int // 1-based line in the source file
+	Rule        string
+	Placeholder string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s:%d %s %s", f.Path, f.Line, f.Rule, f.Placeholder)
+}
+
+// rule finds secrets in a line; the secret is submatch group (0 for the
+// whole match)
+type rule struct {
+	name  string
+	re    *regexp.Regexp
+	group int
+}
+
+var rules = []rule{
+	{"aws-access-key-id", regexp.MustCompile(`\b(?:AKIA|ASIA|AGPA|AIDA|AROA|ANPA)[0-9A-Z]{16}\b`), 0},
+	{"aws-secret-access-key", regexp.MustCompile(`(?i)aws_?secret_?access_?key["']?\s*[:=]\s*["']?([A-Za-z0-9/+]{40})\b`), 1},
+	{"github-token", regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{50,})\b`), 0},
+	{"slack-token", regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}`), 0},
+	{"jwt", regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}`), 0},
+}
+
+// Private key blocks span lines from BEGIN to END
+var (
+	privateKeyBegin = regexp.MustCompile(`-----BEGIN [A-Z0-9 ]*PRIVATE KEY( BLOCK)?-----`)
+	privateKeyEnd   = regexp.MustCompile(`-----END [A-Z0-9 ]*PRIVATE KEY( BLOCK)?-----`)
+)
+
+// envAssignment matches "KEY=value" lines in .env files
+var envAssignment = regexp.MustCompile(`^\s*(?:export\s+)?[A-Za-z_][A-Za-z0-9_.]*\s*=\s*(.*?)\s*$`)
+
+// High-entropy candidates are whole quoted strings and assigned values, so
+// identifiers in code are not mistaken for keys. Hex strings (hashes,
+// commit ids) lack the case mix and are not flagged.
+var (
+	entropyCandidate = regexp.MustCompile("[\"'`]([A-Za-z0-9+/_-]{20,}={0,2})[\"'`]|[:=]\\s*([A-Za-z0-9+/_-]{20,}={0,2})\\s*$")
+	minEntropy       = 4.0
+	minSwitchRatio   = 0.5
+)
+
+// entropySkipped are generated files full of legitimate checksums
+var entropySkipped = []string{"go.sum", "*.lock", "package-lock.json", "pnpm-lock.yaml", "*.snp"}
+
+// Scanner redacts secrets across the files of one snapshot. A secret gets
+// the same placeholder wherever it occurs; placeholders are numbered per
+// rule in order of first occurrence, so unchanged trees redact identically.
+type Scanner struct {
+	placeholders map[string]string
+	counts       map[string]int
+}
+
+// NewScanner creates a scanner with no secrets seen yet
+func NewScanner() *Scanner {
+	return &Scanner{placeholders: make(map[string]string), counts: make(map[string]int)}
+}
+
+// placeholder returns the stable placeholder of a secret
+func (s *Scanner) placeholder(ruleName, secret string) string {
+	key := ruleName + "\x00" + secret
+	if p, ok := s.placeholders[key]; ok {
+		return p
+	}
+	s.counts[ruleName]++
+	p := fmt.Sprintf("[REDACTED:%s:%d]", ruleName, s.counts[ruleName])
+	s.placeholders[key] = p
+	return p
+}
+
+// Redact replaces the secrets in lines of the file at relPath in place and
+// returns what it found. The number of lines is unchanged.
+func (s *Scanner) Redact(relPath string, lines []string) []Finding {
+	return s.redact(relPath, lines, false)
+}
+
+// RedactDiff redacts a unified diff in place. Rules are chosen by the path
+// of each file in the diff; findings name that path, the kind of diff and
+// the line within the file's part of the diff.
+func (s *Scanner) RedactDiff(kind string, lines []string) []Finding {
+	var findings []Finding
+	for _, c := range diffChunks(lines) {
+		for _, f := range s.redact(c.path, c.lines, true) {
+			f.Path = c.path + " (" + kind + ")"
+			findings = append(findings, f)
+		}
+	}
+	return findings
+}
+
+// diffChunk is the part of a unified diff about one file
+type diffChunk struct {
+	path  string
+	lines []string // Shares the diff's backing array
+}
+
+// diffChunks splits a unified diff at its "diff --git a/... b/..." headers
+func diffChunks(lines []string) []diffChunk {
+	var chunks []diffChunk
+	for start := 0; start < len(lines); {
+		end := start + 1
+		for end < len(lines) && !strings.HasPrefix(lines[end], "diff --git ") {
+			end++
+		}
+		var relPath string
+		if i := strings.LastIndex(lines[start], " b/"); i >= 0 && strings.HasPrefix(lines[start], "diff --git ") {
+			relPath = lines[start][i+3:]
+		}
+		chunks = append(chunks, diffChunk{path: relPath, lines: lines[start:end]})
+		start = end
+	}
+	return chunks
+}
+
+// redact implements Redact; diff lines start with a +, - or space marker
+func (s *Scanner) redact(relPath string, lines []string, diff bool) []Finding {
+	var findings []Finding
+	add := func(i int, ruleName, p string) {
+		findings = append(findings, Finding{Path: relPath, Line: i + 1, Rule: ruleName, Placeholder: p})
+	}
+
+	env := isEnvFile(relPath)
+	entropy := !matchesAny(relPath, entropySkipped)
+
+	for i := 0; i < len(lines); i++ {
+		// Replace every line of a private key body
+		if privateKeyBegin.MatchString(lines[i]) {
+			end := i + 1
+			for end < len(lines) && !privateKeyEnd.MatchString(lines[end]) {
+				end++
+			}
+			if end < len(lines) && end > i+1 {
+				p := s.placeholder("private-key", strings.Join(lines[i+1:end], "\n"))
+				for j := i + 1; j < end; j++ {
+					lines[j] = p
+				}
+				add(i+1, "private-key", p)
+				i = end
+				continue
+			}
+		}
+
+		line := lines[i]
+		for _, r := range rules {
+			line = replaceGroup(r.re, line, r.group, func(secret string) string {
+				p := s.placeholder(r.name, secret)
+				add(i, r.name, p)
+				return p
+			})
+		}
+
+		if env {
+			marker := ""
+			if diff && line != "" {
+				marker, line = line[:1], line[1:]
+			}
+			if m := envAssignment.FindStringSubmatchIndex(line); m != nil && m[2] < m[3] {
+				value := strings.Trim(line[m[2]:m[3]], `"'`)
+				if value != "" && !strings.HasPrefix(value, "[REDACTED:") && !strings.HasPrefix(value, "${") {
+					p := s.placeholder("env-value", value)
+					add(i, "env-value", p)
+					line = line[:m[2]] + p + line[m[3]:]
+				}
+			}
+			line = marker + line
+		}
+
+		if entropy {
+			for group := 1; group <= 2; group++ {
+				line = replaceGroup(entropyCandidate, line, group, func(token string) string {
+					if !looksRandom(token) {
+						return token
+					}
+					p := s.placeholder("high-entropy", token)
+					add(i, "high-entropy", p)
+					return p
+				})
+			}
+		}
+
+		lines[i] = line
+	}
+
+	return findings
+}
+
+// replaceGroup replaces submatch group of every match of re in s
+func replaceGroup(re *regexp.Regexp, s string, group int, repl func(string) string) string {
+	matches := re.FindAllStringSubmatchIndex(s, -1)
+	if matches == nil {
+		return s
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		start, end := m[2*group], m[2*group+1]
+		if start < 0 {
+			continue
+		}
+		b.WriteString(s[last:start])
+		b.WriteString(repl(s[start:end]))
+		last = end
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+// looksRandom reports whether a token has the character mix and entropy
+// of a generated key rather than of an identifier, word or path. Random
+// keys switch between upper case, lower case and digits far more often
+// than camelCase identifiers do.
+func looksRandom(token string) bool {
+	token = strings.TrimRight(token, "=")
+
+	class := func(c byte) int {
+		switch {
+		case c >= 'A' && c <= 'Z':
+			return 1
+		case c >= 'a' && c <= 'z':
+			return 2
+		case c >= '0' && c <= '9':
+			return 3
+		}
+		return 4
+	}
+
+	var seen [5]bool
+	switches := 0
+	for i := 0; i < len(token); i++ {
+		seen[class(token[i])] = true
+		if i > 0 && class(token[i]) != class(token[i-1]) {
+			switches++
+		}
+	}
+	if !seen[1] || !seen[2] || !seen[3] {
+		return false
+	}
+
+	return float64(switches)/float64(len(token)-1) >= minSwitchRatio && shannon(token) >= minEntropy
+}
+
+// shannon returns the Shannon entropy of s in bits per character
+func shannon(s string) float64 {
+	counts := make(map[rune]int)
+	for _, c := range s {
+		counts[c]++
+	}
+	var h float64
+	n := float64(len(s))
+	for _, c := range counts {
+		p := float64(c) / n
+		h -= p * math.Log2(p)
+	}
+	return h
+}
+
+// isEnvFile reports whether relPath is a dotenv file holding real values,
+// e.g. .env or .env.production but not .env.example
+func isEnvFile(relPath string) bool {
+	base := path.Base(relPath)
+	if base != ".env" && !strings.HasPrefix(base, ".env.") {
+		return false
+	}
+	switch path.Ext(base) {
+	case ".example", ".sample", ".template", ".dist":
+		return false
+	}
+	return true
+}
+
+// matchesAny reports whether the base name of relPath matches a pattern
+func matchesAny(relPath string, patterns []string) bool {
+	base := path.Base(relPath)
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, base); ok {
+			return true
+		}
+	}
+	return false
+}
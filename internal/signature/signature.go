@@ -0,0 +1,198 @@
+// Package signature signs snapshots with local ed25519 keys. A signature
+// covers the snapshot summary, whose digest line in turn covers the rest of
+// the snapshot, and is stored in a sidecar file next to it.
+package signature
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// magic is the first line of signature files and of the signed payload
+const magic = "snp signature v1"
+
+// keyType prefixes encoded public keys
+const keyType = "ed25519"
+
+// Signature is a detached snapshot signature
+type Signature struct {
+	PublicKey ed25519.PublicKey
+	Sig       []byte
+}
+
+// DefaultKeyPath returns the path of the local signing key, e.g.
+// ~/.config/snp/signing.key
+func DefaultKeyPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "snp", "signing.key"), nil
+}
+
+// PublicKeyPath returns where the public key of the key at keyPath is kept
+func PublicKeyPath(keyPath string) string {
+	return keyPath + ".pub"
+}
+
+// SidecarPath returns the signature file of a snapshot
+func SidecarPath(snapshotPath string) string {
+	return snapshotPath + ".sig"
+}
+
+// LoadOrCreateKey reads the private key at path, generating it (and its
+// public key file) when it does not exist yet
+func LoadOrCreateKey(path string) (key ed25519.PrivateKey, created bool, err error) {
+	key, err = LoadKey(path)
+	if !errors.Is(err, fs.ErrNotExist) {
+		return key, false, err
+	}
+
+	_, key, err = ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, false, err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, false, fmt.Errorf("cannot create key directory: %w", err)
+	}
+	block := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(path, block, 0o600); err != nil {
+		return nil, false, fmt.Errorf("cannot write signing key: %w", err)
+	}
+	pub := FormatPublicKey(key.Public().(ed25519.PublicKey)) + "\n"
+	if err := os.WriteFile(PublicKeyPath(path), []byte(pub), 0o644); err != nil {
+		return nil, false, fmt.Errorf("cannot write public key: %w", err)
+	}
+
+	return key, true, nil
+}
+
+// LoadKey reads a PEM-encoded ed25519 private key
+func LoadKey(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, fmt.Errorf("%s: not a PEM private key", path)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	key, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: not an ed25519 key", path)
+	}
+	return key, nil
+}
+
+// LoadPublicKey reads a public key file as written next to the signing key
+func LoadPublicKey(path string) (ed25519.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pub, err := ParsePublicKey(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return pub, nil
+}
+
+// FormatPublicKey encodes a public key as "ed25519 <base64>"
+func FormatPublicKey(pub ed25519.PublicKey) string {
+	return keyType + " " + base64.StdEncoding.EncodeToString(pub)
+}
+
+// ParsePublicKey decodes a public key encoded by FormatPublicKey
+func ParsePublicKey(s string) (ed25519.PublicKey, error) {
+	typ, enc, ok := strings.Cut(s, " ")
+	if !ok || typ != keyType {
+		return nil, fmt.Errorf("not an %s public key", keyType)
+	}
+	raw, err := base64.StdEncoding.DecodeString(enc)
+	if err != nil || len(raw) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid %s public key", keyType)
+	}
+	return ed25519.PublicKey(raw), nil
+}
+
+// Fingerprint identifies a public key, e.g. "SHA256:Xq3...", as ssh-keygen
+// does
+func Fingerprint(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
+}
+
+// Payload returns the signed bytes for a snapshot summary
+func Payload(summary []string) []byte {
+	return []byte(magic + "\n" + strings.Join(summary, "\n") + "\n")
+}
+
+// Sign signs a payload
+func Sign(key ed25519.PrivateKey, payload []byte) Signature {
+	return Signature{
+		PublicKey: key.Public().(ed25519.PublicKey),
+		Sig:       ed25519.Sign(key, payload),
+	}
+}
+
+// Verify checks that s is a valid signature of payload made with trusted
+func (s Signature) Verify(payload []byte, trusted ed25519.PublicKey) error {
+	if !s.PublicKey.Equal(trusted) {
+		return fmt.Errorf("signed with key %s, not the trusted key %s",
+			Fingerprint(s.PublicKey), Fingerprint(trusted))
+	}
+	if !ed25519.Verify(s.PublicKey, payload, s.Sig) {
+		return errors.New("signature does not match the snapshot")
+	}
+	return nil
+}
+
+// Marshal encodes the signature file
+func (s Signature) Marshal() []byte {
+	return []byte(magic + "\n" +
+		"key: " + FormatPublicKey(s.PublicKey) + "\n" +
+		"signature: " + base64.StdEncoding.EncodeToString(s.Sig) + "\n")
+}
+
+// Parse decodes a signature file
+func Parse(data []byte) (Signature, error) {
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 3 || lines[0] != magic {
+		return Signature{}, errors.New("not an snp signature file")
+	}
+
+	keyLine, ok1 := strings.CutPrefix(lines[1], "key: ")
+	sigLine, ok2 := strings.CutPrefix(lines[2], "signature: ")
+	if !ok1 || !ok2 {
+		return Signature{}, errors.New("malformed signature file")
+	}
+
+	pub, err := ParsePublicKey(keyLine)
+	if err != nil {
+		return Signature{}, err
+	}
+	sig, err := base64.StdEncoding.DecodeString(sigLine)
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return Signature{}, errors.New("malformed signature")
+	}
+	return Signature{PublicKey: pub, Sig: sig}, nil
+}
@@ -0,0 +1,47 @@
+package signature_test
+
+import (
+	"crypto/ed25519"
+	"path/filepath"
+	"testing"
+
+	"github.com/neox5/snp/internal/signature"
+)
+
+func TestSignVerify(t *testing.T) {
+	keyPath := filepath.Join(t.TempDir(), "signing.key")
+	key, created, err := signature.LoadOrCreateKey(keyPath)
+	if err != nil || !created {
+		t.Fatalf("LoadOrCreateKey = %v, %v", created, err)
+	}
+	again, created, err := signature.LoadOrCreateKey(keyPath)
+	if err != nil || created || !again.Equal(key) {
+		t.Fatalf("reloading the key: created = %v, err = %v", created, err)
+	}
+	pub, err := signature.LoadPublicKey(signature.PublicKeyPath(keyPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	summary := []string{"Generated: 2025-01-01 00:00:00", "Digest: sha256:00"}
+	sig, err := signature.Parse(signature.Sign(key, signature.Payload(summary)).Marshal())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sig.Verify(signature.Payload(summary), pub); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+	tampered := []string{"Generated: 2025-01-01 00:00:00", "Digest: sha256:01"}
+	if err := sig.Verify(signature.Payload(tampered), pub); err == nil {
+		t.Error("Verify accepted a tampered summary")
+	}
+
+	other, _, err := signature.LoadOrCreateKey(filepath.Join(t.TempDir(), "other.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sig.Verify(signature.Payload(summary), other.Public().(ed25519.PublicKey)); err == nil {
+		t.Error("Verify accepted an untrusted key")
+	}
+}
@@ -0,0 +1,239 @@
+// Package snapdiff compares two parsed snapshots file by file.
+package snapdiff
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/neox5/snp/internal/file"
+	"github.com/neox5/snp/internal/reader"
+	"github.com/neox5/snp/internal/textdiff"
+)
+
+// File change statuses
+const (
+	Added    = "added"
+	Removed  = "removed"
+	Modified = "modified"
+)
+
+// DefaultContext is the number of unified diff context lines
+const DefaultContext = 3
+
+// Snapshot identifies one side of a comparison
+type Snapshot struct {
+	Path      string `json:"path"`
+	Generated string `json:"generated,omitempty"`
+	Revision  string `json:"revision,omitempty"`
+	Files     int    `json:"files"`
+}
+
+// Summary counts files by status
+type Summary struct {
+	Added     int `json:"added"`
+	Removed   int `json:"removed"`
+	Modified  int `json:"modified"`
+	Unchanged int `json:"unchanged"`
+}
+
+// FileChange is an added, removed or modified file
+type FileChange struct {
+	Path         string   `json:"path"`
+	Status       string   `json:"status"`
+	Binary       bool     `json:"binary,omitempty"`
+	OldSize      string   `json:"old_size,omitempty"`
+	NewSize      string   `json:"new_size,omitempty"`
+	SizeDelta    int64    `json:"size_delta"` // approximate for sizes of 1 KB and more
+	LinesAdded   int      `json:"lines_added"`
+	LinesRemoved int      `json:"lines_removed"`
+	Diff         []string `json:"diff,omitempty"`
+}
+
+// Result is the comparison of two snapshots
+type Result struct {
+	Old     Snapshot     `json:"old"`
+	New     Snapshot     `json:"new"`
+	Summary Summary      `json:"summary"`
+	Files   []FileChange `json:"files"`
+}
+
+// Compare compares snapshot a (old) with b (new). Text files are compared
+// by content; binary files, whose content is not stored, by indexed size.
+func Compare(aPath string, a *reader.Snapshot, bPath string, b *reader.Snapshot, context int) *Result {
+	res := &Result{Old: describe(aPath, a), New: describe(bPath, b)}
+
+	paths := make(map[string]bool)
+	for _, f := range a.Files {
+		paths[f.RelPath] = true
+	}
+	for _, f := range b.Files {
+		paths[f.RelPath] = true
+	}
+	sorted := make([]string, 0, len(paths))
+	for p := range paths {
+		sorted = append(sorted, p)
+	}
+	sort.Strings(sorted)
+
+	for _, p := range sorted {
+		change, ok := compareFile(p, a.File(p), b.File(p), context)
+		if !ok {
+			res.Summary.Unchanged++
+			continue
+		}
+
+		switch change.Status {
+		case Added:
+			res.Summary.Added++
+		case Removed:
+			res.Summary.Removed++
+		case Modified:
+			res.Summary.Modified++
+		}
+		res.Files = append(res.Files, change)
+	}
+
+	return res
+}
+
+func describe(path string, s *reader.Snapshot) Snapshot {
+	return Snapshot{
+		Path:      path,
+		Generated: s.Fields["Generated"],
+		Revision:  s.Fields["Revision"],
+		Files:     len(s.Files),
+	}
+}
+
+// compareFile reports the change of one path; ok is false when unchanged
+func compareFile(path string, old, cur *reader.File, context int) (FileChange, bool) {
+	change := FileChange{Path: path}
+
+	var oldLines, curLines []string
+	switch {
+	case old == nil:
+		change.Status = Added
+		change.Binary = cur.Binary
+		change.NewSize = cur.Size
+		curLines = cur.Lines
+	case cur == nil:
+		change.Status = Removed
+		change.Binary = old.Binary
+		change.OldSize = old.Size
+		oldLines = old.Lines
+	default:
+		change.Status = Modified
+		change.Binary = old.Binary || cur.Binary
+		change.OldSize, change.NewSize = old.Size, cur.Size
+		oldLines, curLines = old.Lines, cur.Lines
+
+		if change.Binary && old.Binary == cur.Binary && old.Size == cur.Size {
+			return change, false
+		}
+	}
+
+	oldSize, _ := reader.ParseSize(change.OldSize)
+	newSize, _ := reader.ParseSize(change.NewSize)
+	change.SizeDelta = newSize - oldSize
+
+	if change.Binary {
+		return change, true
+	}
+
+	edits := textdiff.Lines(oldLines, curLines)
+	change.LinesAdded, change.LinesRemoved = textdiff.Count(edits)
+	if change.Status == Modified && change.LinesAdded == 0 && change.LinesRemoved == 0 {
+		return change, false
+	}
+
+	oldName, newName := "a/"+path, "b/"+path
+	if old == nil {
+		oldName = "/dev/null"
+	}
+	if cur == nil {
+		newName = "/dev/null"
+	}
+	change.Diff = textdiff.Unified(oldName, newName, edits, context)
+	return change, true
+}
+
+// Changed reports whether any file differs
+func (r *Result) Changed() bool {
+	return len(r.Files) > 0
+}
+
+// Lines renders the comparison: header, summary, file list and diffs
+func (r *Result) Lines() []string {
+	lines := []string{
+		"Old: " + r.Old.label(),
+		"New: " + r.New.label(),
+		"",
+		fmt.Sprintf("Added:     %d", r.Summary.Added),
+		fmt.Sprintf("Removed:   %d", r.Summary.Removed),
+		fmt.Sprintf("Modified:  %d", r.Summary.Modified),
+		fmt.Sprintf("Unchanged: %d", r.Summary.Unchanged),
+	}
+
+	if len(r.Files) == 0 {
+		return lines
+	}
+
+	lines = append(lines, "")
+	lines = append(lines, r.FileList()...)
+
+	for _, f := range r.Files {
+		if len(f.Diff) > 0 {
+			lines = append(lines, "")
+			lines = append(lines, f.Diff...)
+		}
+	}
+
+	return lines
+}
+
+// FileList renders one line per changed file, e.g. "M  path (+3 -1)"
+func (r *Result) FileList() []string {
+	lines := make([]string, 0, len(r.Files))
+	for _, f := range r.Files {
+		lines = append(lines, f.label())
+	}
+	return lines
+}
+
+func (s Snapshot) label() string {
+	label := fmt.Sprintf("%s (%d files", s.Path, s.Files)
+	if s.Generated != "" {
+		label += ", generated " + s.Generated
+	}
+	if s.Revision != "" {
+		label += ", revision " + s.Revision
+	}
+	return label + ")"
+}
+
+// label renders a file list line like "M  path (+3 -1)"
+func (f FileChange) label() string {
+	mark := map[string]string{Added: "A", Removed: "D", Modified: "M"}[f.Status]
+
+	var detail string
+	switch {
+	case f.Binary && f.Status == Modified:
+		detail = fmt.Sprintf("binary, %s -> %s, %s", f.OldSize, f.NewSize, formatDelta(f.SizeDelta))
+	case f.Binary && f.Status == Added:
+		detail = "binary, " + f.NewSize
+	case f.Binary:
+		detail = "binary, " + f.OldSize
+	default:
+		detail = fmt.Sprintf("+%d -%d", f.LinesAdded, f.LinesRemoved)
+	}
+
+	return fmt.Sprintf("%s  %s (%s)", mark, f.Path, detail)
+}
+
+// formatDelta formats a signed size difference
+func formatDelta(delta int64) string {
+	if delta < 0 {
+		return "-" + file.FormatSize(-delta)
+	}
+	return "+" + file.FormatSize(delta)
+}
@@ -0,0 +1,173 @@
+package snapdiff_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/neox5/snp/internal/reader"
+	"github.com/neox5/snp/internal/snapdiff"
+	"github.com/neox5/snp/internal/snapshot"
+)
+
+// parsedSnapshot snapshots files and parses the result
+func parsedSnapshot(t *testing.T, files map[string]string) *reader.Snapshot {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := snapshot.Config{SourceDir: dir, OutputPath: filepath.Join(t.TempDir(), "out.snp")}
+	snap, err := snapshot.Build(context.Background(), cfg, dir, cfg.OutputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err := snap.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := reader.Parse(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed
+}
+
+// compare compares two snapshots covering every kind of change
+func compare(t *testing.T) *snapdiff.Result {
+	t.Helper()
+	old := parsedSnapshot(t, map[string]string{
+		"main.go":  "package main\n\nfunc main() {}\n",
+		"gone.txt": "bye\n",
+		"same.txt": "same\n",
+		"blob.bin": "\x00\x01\x02",
+		"same.bin": "\x00\x01\x02",
+	})
+	cur := parsedSnapshot(t, map[string]string{
+		"main.go":  "package main\n\nfunc main() { run() }\n",
+		"new.txt":  "hi\n",
+		"same.txt": "same\n",
+		"blob.bin": "\x00\x01\x02\x03\x04",
+		"same.bin": "\x00\x02\x01",
+	})
+	return snapdiff.Compare("old.snp", old, "new.snp", cur, snapdiff.DefaultContext)
+}
+
+func TestCompare_Summary(t *testing.T) {
+	res := compare(t)
+
+	want := snapdiff.Summary{Added: 1, Removed: 1, Modified: 2, Unchanged: 2}
+	if res.Summary != want {
+		t.Errorf("Summary = %+v, want %+v", res.Summary, want)
+	}
+	if !res.Changed() {
+		t.Error("Changed = false, want true")
+	}
+
+	wantList := []string{
+		"M  blob.bin (binary, 3 bytes -> 5 bytes, +2 bytes)",
+		"D  gone.txt (+0 -1)",
+		"M  main.go (+1 -1)",
+		"A  new.txt (+1 -0)",
+	}
+	if got := res.FileList(); !slices.Equal(got, wantList) {
+		t.Errorf("FileList = %q, want %q", got, wantList)
+	}
+}
+
+func TestCompare_Unchanged(t *testing.T) {
+	snap := parsedSnapshot(t, map[string]string{"main.go": "package main\n", "blob.bin": "\x00"})
+
+	res := snapdiff.Compare("a.snp", snap, "b.snp", snap, snapdiff.DefaultContext)
+	if res.Changed() || res.Summary.Unchanged != 2 {
+		t.Errorf("Summary = %+v, want 2 unchanged", res.Summary)
+	}
+	if lines := res.Lines(); len(lines) != 7 {
+		t.Errorf("Lines = %q, want header and summary only", lines)
+	}
+}
+
+func TestCompare_Diff(t *testing.T) {
+	res := compare(t)
+
+	diffs := make(map[string][]string)
+	for _, f := range res.Files {
+		diffs[f.Path] = f.Diff
+	}
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{"blob.bin", nil},
+		{"gone.txt", []string{"--- a/gone.txt", "+++ /dev/null", "@@ -1 +0,0 @@", "-bye"}},
+		{"main.go", []string{
+			"--- a/main.go", "+++ b/main.go", "@@ -1,3 +1,3 @@",
+			" package main", " ", "-func main() {}", "+func main() { run() }",
+		}},
+		{"new.txt", []string{"--- /dev/null", "+++ b/new.txt", "@@ -0,0 +1 @@", "+hi"}},
+	}
+	for _, tt := range tests {
+		if got := diffs[tt.path]; !slices.Equal(got, tt.want) {
+			t.Errorf("%s diff = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+
+	lines := res.Lines()
+	if !slices.Contains(lines, "Modified:  2") || !slices.Contains(lines, "-func main() {}") {
+		t.Errorf("Lines lack the summary or the diffs:\n%q", lines)
+	}
+}
+
+func TestCompare_JSON(t *testing.T) {
+	b, err := json.Marshal(compare(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got struct {
+		Old, New struct {
+			Path  string
+			Files int
+		}
+		Summary map[string]int
+		Files   []map[string]any
+	}
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Old.Path != "old.snp" || got.Old.Files != 5 || got.New.Path != "new.snp" || got.New.Files != 5 {
+		t.Errorf("old = %+v, new = %+v", got.Old, got.New)
+	}
+	wantSummary := map[string]int{"added": 1, "removed": 1, "modified": 2, "unchanged": 2}
+	if !reflect.DeepEqual(got.Summary, wantSummary) {
+		t.Errorf("summary = %v, want %v", got.Summary, wantSummary)
+	}
+	if len(got.Files) != 4 {
+		t.Fatalf("got %d files, want 4", len(got.Files))
+	}
+
+	blob := got.Files[0]
+	if blob["path"] != "blob.bin" || blob["binary"] != true || blob["size_delta"] != 2.0 {
+		t.Errorf("blob.bin = %v", blob)
+	}
+	if _, ok := blob["diff"]; ok {
+		t.Error("binary file has a diff")
+	}
+	main := got.Files[2]
+	if _, ok := main["binary"]; ok || main["status"] != snapdiff.Modified || main["lines_added"] != 1.0 {
+		t.Errorf("main.go = %v", main)
+	}
+}
@@ -0,0 +1,188 @@
+package snapshot
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/neox5/snp/internal/file"
+	"github.com/neox5/snp/internal/token"
+	"github.com/neox5/snp/internal/writer"
+)
+
+// minTruncatedTokens is the smallest remainder worth keeping when a file
+// is truncated; below this the file is dropped instead
+const minTruncatedTokens = 64
+
+// Cut records a file dropped or truncated to fit the token budget
+type Cut struct {
+	RelPath    string
+	Dropped    bool
+	KeptLines  int
+	TotalLines int
+	Tokens     int // Estimated tokens removed
+}
+
+// String renders the cut as a single report line
+func (c Cut) String() string {
+	if c.Dropped {
+		return fmt.Sprintf("%s (dropped, ~%d tokens)", c.RelPath, c.Tokens)
+	}
+	return fmt.Sprintf("%s (truncated to %d of %d lines, ~%d tokens omitted)",
+		c.RelPath, c.KeptLines, c.TotalLines, c.Tokens)
+}
+
+// fitBudget drops or truncates files until the estimated token total fits
+// maxTokens. Files are ordered by priority, so cutting starts at the end.
+func (s *Snapshot) fitBudget() {
+	costs := make([]int, len(s.Files))
+	for i, f := range s.Files {
+		costs[i] = s.fileCost(f)
+	}
+
+	total := s.totalTokens
+
+	kept := len(s.Files)
+	var cuts []Cut
+
+	for kept > 0 && total > s.maxTokens {
+		f := s.Files[kept-1]
+		excess := total - s.maxTokens
+
+		// Each cut adds a line to the budget report
+		cutLine := s.tokenizer.Count(f.RelPath) + 8
+		excess += cutLine
+
+		// Truncate the file if the remainder is still worth keeping
+		if !f.IsBinary && f.Tokens-excess >= minTruncatedTokens {
+			before := f.Tokens
+			totalLines := len(f.Lines)
+			keptLines := truncateLines(f, f.Tokens-excess, s.tokenizer)
+			if keptLines > 0 {
+				total += f.Tokens - before + cutLine
+				cuts = append(cuts, Cut{
+					RelPath:    f.RelPath,
+					KeptLines:  keptLines,
+					TotalLines: totalLines,
+					Tokens:     before - f.Tokens,
+				})
+				break
+			}
+		}
+
+		total -= costs[kept-1]
+		total += cutLine
+		cuts = append(cuts, Cut{
+			RelPath:    f.RelPath,
+			Dropped:    true,
+			TotalLines: len(f.Lines),
+			Tokens:     f.Tokens,
+		})
+		kept--
+	}
+
+	s.Files = s.Files[:kept]
+	s.Cuts = cuts
+}
+
+// dropLast drops the lowest-priority file, turning an earlier truncation
+// of it into a drop
+func (s *Snapshot) dropLast() {
+	f := s.Files[len(s.Files)-1]
+	s.Files = s.Files[:len(s.Files)-1]
+
+	for i, c := range s.Cuts {
+		if c.RelPath == f.RelPath {
+			s.Cuts[i] = Cut{RelPath: f.RelPath, Dropped: true, TotalLines: c.TotalLines, Tokens: c.Tokens + f.Tokens}
+			return
+		}
+	}
+	s.Cuts = append(s.Cuts, Cut{RelPath: f.RelPath, Dropped: true, TotalLines: len(f.Lines), Tokens: f.Tokens})
+}
+
+// fileCost estimates the tokens a file contributes: its section header,
+// content, spacing and index entry
+func (s *Snapshot) fileCost(f *file.File) int {
+	return s.tokenizer.Count("# "+f.RelPath) +
+		f.Tokens +
+		s.tokenizer.Count(f.RelPath) + 12 + // index entry
+		2 // spacing
+}
+
+// truncateLines keeps the leading lines of f that fit within limit tokens
+// and appends a marker line. Returns the number of content lines kept.
+func truncateLines(f *file.File, limit int, tok token.Tokenizer) int {
+	limit -= tok.Count(truncationMarker(len(f.Lines)))
+
+	used := 0
+	keep := 0
+	for _, line := range f.OutputLines() {
+		n := tok.Count(line) + 1 // +1 for the newline
+		if used+n > limit {
+			break
+		}
+		used += n
+		keep++
+	}
+
+	if keep == 0 {
+		return 0
+	}
+
+	f.Lines = append(f.Lines[:keep:keep], truncationMarker(len(f.Lines)-keep))
+	if len(f.Annotations) > keep {
+		f.Annotations = f.Annotations[:keep]
+	}
+	f.Tokens = token.CountLines(tok, f.OutputLines())
+	return keep
+}
+
+// truncationMarker is the line appended to a truncated file
+func truncationMarker(omitted int) string {
+	return fmt.Sprintf("[... %d lines truncated to fit token budget ...]", omitted)
+}
+
+// countTokens estimates the tokens in a layout. File contents use their
+// precomputed counts. The summary reports the total it is part of, so the
+// total is raised until it also covers the summary rendering it.
+func countTokens(layout []Content, tok token.Tokenizer) int {
+	total := 0
+	var sum *summary
+	for _, c := range layout {
+		switch c := c.(type) {
+		case summary:
+			sum = &c
+		case fileContent:
+			total += c.File.Tokens
+		default:
+			total += tok.Count(render(c))
+		}
+	}
+	if sum == nil {
+		return total
+	}
+
+	// More digits can only add tokens, so this settles at the smallest
+	// total that covers itself
+	n := total
+	for {
+		*sum.TotalTokens = n
+		next := total + tok.Count(render(*sum))
+		if next <= n {
+			return n
+		}
+		n = next
+	}
+}
+
+// render writes a content item into a string
+func render(c Content) string {
+	var sb strings.Builder
+	lt := writer.NewLineTracker(&sb)
+	if err := c.WriteTo(lt); err != nil {
+		return ""
+	}
+	if err := lt.Flush(); err != nil {
+		return ""
+	}
+	return sb.String()
+}
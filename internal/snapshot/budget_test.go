@@ -0,0 +1,110 @@
+package snapshot_test
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/neox5/snp/internal/snapshot"
+	"github.com/neox5/snp/internal/token"
+)
+
+// reportedTokens parses the "Total tokens: ~N (tokenizer)" summary line
+func reportedTokens(t *testing.T, output string) int {
+	t.Helper()
+	v := summaryField(output, "Total tokens")
+	n, err := strconv.Atoi(strings.TrimPrefix(strings.Fields(v)[0], "~"))
+	if err != nil {
+		t.Fatalf("Total tokens = %q", v)
+	}
+	return n
+}
+
+// sourceFiles returns n files of about size characters each
+func sourceFiles(n, size int) map[string]string {
+	files := make(map[string]string, n)
+	for i := 0; i < n; i++ {
+		var b strings.Builder
+		for j := 0; b.Len() < size; j++ {
+			fmt.Fprintf(&b, "line %d of file %d\n", j, i)
+		}
+		files[fmt.Sprintf("src/file%02d.txt", i)] = b.String()
+	}
+	return files
+}
+
+func TestTotalTokens_IncludesSummary(t *testing.T) {
+	dir := writeTree(t, sourceFiles(5, 400))
+	_, output := build(t, snapshot.Config{SourceDir: dir})
+
+	tok, _ := token.New(token.Heuristic)
+	actual := tok.Count(output)
+	reported := reportedTokens(t, output)
+
+	// Per-item rounding may overestimate slightly, but never by more than
+	// a token per line, and the estimate must not fall short
+	lines := strings.Count(output, "\n")
+	if reported < actual || reported > actual+lines {
+		t.Errorf("reported ~%d tokens, output has %d", reported, actual)
+	}
+}
+
+func TestFitBudget(t *testing.T) {
+	dir := writeTree(t, sourceFiles(8, 2000))
+	tok, _ := token.New(token.Heuristic)
+
+	for _, budget := range []int{3000, 2000, 1200, 700} {
+		t.Run(strconv.Itoa(budget), func(t *testing.T) {
+			snap, output := build(t, snapshot.Config{SourceDir: dir, MaxTokens: budget})
+
+			if reported := reportedTokens(t, output); reported > budget {
+				t.Errorf("reported ~%d tokens over budget %d", reported, budget)
+			}
+			if actual := tok.Count(output); actual > budget {
+				t.Errorf("output has %d tokens over budget %d", actual, budget)
+			}
+			if len(snap.Cuts) == 0 {
+				t.Fatal("no files cut")
+			}
+
+			// Cuts start at the lowest-priority file and every cut is
+			// reported once
+			seen := make(map[string]bool)
+			for _, c := range snap.Cuts {
+				if seen[c.RelPath] {
+					t.Errorf("%s cut twice", c.RelPath)
+				}
+				seen[c.RelPath] = true
+			}
+			for _, f := range snap.Files {
+				for _, c := range snap.Cuts {
+					if c.Dropped && c.RelPath < f.RelPath {
+						t.Errorf("dropped %s but kept later file %s", c.RelPath, f.RelPath)
+					}
+				}
+			}
+			if got := len(section(output, "Token Budget")); got != len(snap.Cuts) {
+				t.Errorf("Token Budget section has %d lines, want %d", got, len(snap.Cuts))
+			}
+		})
+	}
+}
+
+func TestFitBudget_Truncates(t *testing.T) {
+	dir := writeTree(t, map[string]string{
+		"a.txt": strings.Repeat("first file line\n", 20),
+		"b.txt": strings.Repeat("second file line\n", 200),
+	})
+
+	snap, output := build(t, snapshot.Config{SourceDir: dir, MaxTokens: 600})
+	if len(snap.Cuts) != 1 || snap.Cuts[0].RelPath != "b.txt" || snap.Cuts[0].Dropped {
+		t.Fatalf("cuts = %v, want b.txt truncated", snap.Cuts)
+	}
+	if !strings.Contains(output, "lines truncated to fit token budget ...]") {
+		t.Error("missing truncation marker")
+	}
+	if len(snap.Files) != 2 {
+		t.Errorf("kept %d files, want 2", len(snap.Files))
+	}
+}
@@ -1,13 +1,69 @@
 package snapshot
 
+import (
+	"github.com/neox5/snp/internal/file"
+	"github.com/neox5/snp/internal/gitlog"
+	"github.com/neox5/snp/internal/secrets"
+)
+
 // Config holds the runtime configuration for a snapshot run.
 type Config struct {
-	SourceDir           string
-	OutputPath          string
-	IncludePatterns     []string
-	ExcludePatterns     []string
-	IncludeGitLog       bool
-	DryRun              bool
-	ForceTextPatterns   []string
-	ForceBinaryPatterns []string
+	SourceDir             string
+	OutputPath            string
+	IncludePatterns       []string
+	ExcludePatterns       []string
+	IncludeGitLog         bool
+	GitLogOptions         gitlog.Options
+	IncludeGitDiff        bool
+	IncludeGitMeta        bool
+	Blame                 bool
+	BlameSuffix           bool
+	ChangedSince          string
+	WithNeighbors         bool
+	Rev                   string
+	DryRun                bool
+	ForceTextPatterns     []string
+	ForceBinaryPatterns   []string
+	ForceEncodingPatterns []string // GLOB=ENCODING overrides of encoding detection
+	Submodules            string
+	Tokenizer             string
+	MaxTokens             int
+	SplitSize             SplitSize
+	PriorityPatterns      []string
+	SortBy                string
+	IncludeTree           bool
+	TreeExcluded          bool
+	IncludeStats          bool
+	IncludeHashes         bool
+	Compress              bool           // gzip the output; implied by a .gz output path
+	KeepSecrets           bool           // Skip secret detection and redaction
+	FailOnSecrets         bool           // Abort with a *SecretsError instead of redacting
+	RedactRules           []secrets.Rule // Applied to content and the git log
+	EscapeUnicode         bool           // Show suspicious characters as \uXXXX escapes
+
+	// CacheDir holds the content cache shared between runs; empty
+	// disables caching
+	CacheDir string
+
+	// Options are the command-line options recorded in the summary so
+	// the snapshot can be rebuilt with the same settings (snp check)
+	Options []string
+}
+
+// CollectOptions returns the file selection of the config. There is no
+// output file to exclude when writing to stdout.
+func (cfg Config) CollectOptions(absSourceDir, absOutput string) file.CollectOptions {
+	if absOutput == Stdout {
+		absOutput = ""
+	}
+	return file.CollectOptions{
+		SourceDir:             absSourceDir,
+		OutputPath:            absOutput,
+		ExcludePatterns:       cfg.ExcludePatterns,
+		IncludePatterns:       cfg.IncludePatterns,
+		ForceTextPatterns:     cfg.ForceTextPatterns,
+		ForceBinaryPatterns:   cfg.ForceBinaryPatterns,
+		ForceEncodingPatterns: cfg.ForceEncodingPatterns,
+		Submodules:            cfg.Submodules,
+	}
 }
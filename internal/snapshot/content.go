@@ -2,8 +2,10 @@ package snapshot
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/neox5/snp/internal/file"
+	"github.com/neox5/snp/internal/unicheck"
 	"github.com/neox5/snp/internal/writer"
 )
 
@@ -22,36 +24,74 @@ type summary struct {
 	TextFiles   int
 	BinaryFiles int
 	TotalLines  *int // Pointer to allow updating after layout construction
+	TotalTokens *int // Pointer to allow updating after layout construction
+	Tokenizer   string
+	MaxTokens   int
+	SplitSize   SplitSize
+	TotalParts  *int     // Pointer to allow updating after splitting
+	Stats       []string // Rendered language statistics (optional)
+	Revision    string   // Git revision the snapshot was taken from (optional)
+	Options     string   // Command-line options that shaped the content (optional)
+	Digest      *string  // Whole-snapshot digest, filled in after layout construction (optional)
+	Unicode     string   // Files with suspicious characters (optional)
 }
 
 func (s summary) LineCount() int {
-	return 3
+	return len(s.lines())
 }
 
 func (s summary) WriteTo(lt *writer.LineTracker) error {
-	if err := lt.WriteLine("Generated: " + s.Timestamp); err != nil {
-		return err
+	for _, line := range s.lines() {
+		if err := lt.WriteLine(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lines renders the summary; the line count depends only on configuration
+func (s summary) lines() []string {
+	lines := []string{
+		"Generated: " + s.Timestamp,
 	}
 
-	summary := fmt.Sprintf("Total files: %d (%d text, %d binary)",
-		s.TotalFiles, s.TextFiles, s.BinaryFiles)
-	if err := lt.WriteLine(summary); err != nil {
-		return err
+	if s.Revision != "" {
+		lines = append(lines, "Revision: "+s.Revision)
 	}
 
-	totalLinesStr := fmt.Sprintf("Total lines: %d", *s.TotalLines)
-	return lt.WriteLine(totalLinesStr)
-}
+	lines = append(lines,
+		fmt.Sprintf("Total files: %d (%d text, %d binary)",
+			s.TotalFiles, s.TextFiles, s.BinaryFiles),
+		fmt.Sprintf("Total lines: %d", *s.TotalLines),
+		fmt.Sprintf("Total tokens: ~%d (%s)", *s.TotalTokens, s.Tokenizer),
+	)
+
+	if s.MaxTokens > 0 {
+		lines = append(lines, fmt.Sprintf("Token budget: %d", s.MaxTokens))
+	}
+
+	if s.SplitSize.Limit > 0 {
+		lines = append(lines, fmt.Sprintf("Parts: %d (split size %s)", *s.TotalParts, s.SplitSize))
+	}
+
+	if s.Unicode != "" {
+		lines = append(lines, "Suspicious Unicode: "+s.Unicode)
+	}
+
+	if s.Options != "" {
+		lines = append(lines, "Options: "+s.Options)
+	}
+
+	if s.Digest != nil {
+		lines = append(lines, "Digest: sha256:"+*s.Digest)
+	}
 
-// newSummary creates a new summary content item with mutable totalLines
-func newSummary(timestamp string, totalFiles, textFiles, binaryFiles int, totalLines *int) Content {
-	return summary{
-		Timestamp:   timestamp,
-		TotalFiles:  totalFiles,
-		TextFiles:   textFiles,
-		BinaryFiles: binaryFiles,
-		TotalLines:  totalLines,
+	if len(s.Stats) > 0 {
+		lines = append(lines, "")
+		lines = append(lines, s.Stats...)
 	}
+
+	return lines
 }
 
 // ===== Primitive Content Types =====
@@ -108,17 +148,17 @@ func newEmptyLine() Content {
 
 // ===== Content Types =====
 
-// gitLog represents git log lines
-type gitLog struct {
-	Lines GitLogLines
+// lineBlock renders preformatted lines verbatim, such as the git log
+type lineBlock struct {
+	Lines []string
 }
 
-func (g gitLog) LineCount() int {
-	return len(g.Lines)
+func (b lineBlock) LineCount() int {
+	return len(b.Lines)
 }
 
-func (g gitLog) WriteTo(lt *writer.LineTracker) error {
-	for _, line := range g.Lines {
+func (b lineBlock) WriteTo(lt *writer.LineTracker) error {
+	for _, line := range b.Lines {
 		if err := lt.WriteLine(line); err != nil {
 			return err
 		}
@@ -126,43 +166,102 @@ func (g gitLog) WriteTo(lt *writer.LineTracker) error {
 	return nil
 }
 
-// newGitLog creates a new git log content item
-func newGitLog(lines GitLogLines) Content {
-	return gitLog{Lines: lines}
+// newLineBlock creates a new preformatted lines content item
+func newLineBlock(lines []string) Content {
+	return lineBlock{Lines: lines}
 }
 
 // index renders all file index entries
 type index struct {
-	Files []*file.File
+	Files     []*file.File
+	Collapsed []string // Entries without content, e.g. collapsed submodules
+	Hashes    bool     // Show each file's SHA-256
 }
 
 func (idx index) LineCount() int {
-	return len(idx.Files)
+	return len(idx.Files) + len(idx.Collapsed)
 }
 
 func (idx index) WriteTo(lt *writer.LineTracker) error {
 	for _, f := range idx.Files {
-		var line string
-		endLine := f.StartLine + len(f.Lines) - 1
-		lineCount := len(f.Lines)
-
-		if f.IsBinary {
-			sizeStr := formatSize(f.Size)
-			line = fmt.Sprintf("%s [%d-%d] (binary, %s)",
-				f.RelPath, f.StartLine, endLine, sizeStr)
-		} else {
-			sizeStr := formatSize(f.Size)
-			line = fmt.Sprintf("%s [%d-%d] (%d lines, %s)",
-				f.RelPath, f.StartLine, endLine, lineCount, sizeStr)
-		}
+		line := fmt.Sprintf("%s [%d-%d] (%s)",
+			f.RelPath, f.StartLine, f.EndLine, strings.Join(indexAttrs(f, idx.Hashes), ", "))
 
 		if err := lt.WriteLine(line); err != nil {
 			return err
 		}
 	}
+	for _, line := range idx.Collapsed {
+		if err := lt.WriteLine(line); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// indexAttrs returns the parenthesized attributes of a file index entry
+func indexAttrs(f *file.File, showHash bool) []string {
+	var attrs []string
+
+	switch {
+	case f.Part > 0 && f.EndPart > f.Part:
+		attrs = append(attrs, fmt.Sprintf("parts %d-%d", f.Part, f.EndPart))
+	case f.Part > 0:
+		attrs = append(attrs, fmt.Sprintf("part %d", f.Part))
+	}
+
+	if f.GitStatus != "" {
+		attrs = append(attrs, f.GitStatus)
+	}
+	if f.ChangeMark != "" {
+		attrs = append(attrs, f.ChangeMark)
+	}
+	if f.Git != nil && f.Git.Commits > 0 {
+		attrs = append(attrs,
+			fmt.Sprintf("%s %s %s", f.Git.Commit, f.Git.Date, f.Git.Author),
+			formatCount(f.Git.Commits, "commit", "commits"),
+		)
+	}
+
+	sizeStr := formatSize(f.Size)
+	if f.IsBinary {
+		attrs = append(attrs, "binary", sizeStr)
+	} else {
+		if f.Encoding != "" {
+			attrs = append(attrs, f.Encoding)
+		}
+		attrs = append(attrs,
+			fmt.Sprintf("%d lines", len(f.Lines)),
+			sizeStr,
+			fmt.Sprintf("~%d tokens", f.Tokens),
+		)
+	}
+
+	if len(f.Unicode) > 0 {
+		attrs = append(attrs, "suspicious unicode: "+unicheck.Describe(f.Unicode))
+	}
+	if f.Redactions > 0 {
+		attrs = append(attrs, formatCount(f.Redactions, "replacement", "replacements"))
+	}
+	if f.Secrets > 0 {
+		attrs = append(attrs, formatCount(f.Secrets, "secret redacted", "secrets redacted"))
+	}
+
+	if showHash && f.Hash != "" {
+		attrs = append(attrs, "sha256:"+f.Hash)
+	}
+
+	return attrs
+}
+
+// formatCount formats a count with singular or plural noun
+func formatCount(n int, singular, plural string) string {
+	if n == 1 {
+		return "1 " + singular
+	}
+	return fmt.Sprintf("%d %s", n, plural)
+}
+
 // formatSize formats byte size in human-readable format
 func formatSize(bytes int64) string {
 	const (
@@ -188,8 +287,51 @@ func formatSize(bytes int64) string {
 }
 
 // newIndex creates a new file index content item
-func newIndex(files []*file.File) Content {
-	return index{Files: files}
+func newIndex(files []*file.File, collapsed []string, hashes bool) Content {
+	return index{Files: files, Collapsed: collapsed, Hashes: hashes}
+}
+
+// fileChunk renders a slice of a file's content, used when a file is
+// split across parts
+type fileChunk struct {
+	File     *file.File
+	From, To int // Line slice bounds [From, To)
+}
+
+func (c fileChunk) LineCount() int {
+	return c.To - c.From
+}
+
+func (c fileChunk) WriteTo(lt *writer.LineTracker) error {
+	return writeFileLines(lt, c.File, c.From, c.To)
+}
+
+// newFileChunk creates a new file chunk content item
+func newFileChunk(f *file.File, from, to int) Content {
+	return fileChunk{File: f, From: from, To: to}
+}
+
+// budgetReport lists files cut to fit the token budget
+type budgetReport struct {
+	Cuts []Cut
+}
+
+func (b budgetReport) LineCount() int {
+	return len(b.Cuts)
+}
+
+func (b budgetReport) WriteTo(lt *writer.LineTracker) error {
+	for _, c := range b.Cuts {
+		if err := lt.WriteLine(c.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newBudgetReport creates a new token budget report content item
+func newBudgetReport(cuts []Cut) Content {
+	return budgetReport{Cuts: cuts}
 }
 
 // fileContent renders a single file's content
@@ -202,8 +344,19 @@ func (f fileContent) LineCount() int {
 }
 
 func (f fileContent) WriteTo(lt *writer.LineTracker) error {
-	for _, line := range f.File.Lines {
-		if err := lt.WriteLine(line); err != nil {
+	return writeFileLines(lt, f.File, 0, len(f.File.Lines))
+}
+
+// writeFileLines writes lines [from, to) of f, applying annotations
+func writeFileLines(lt *writer.LineTracker, f *file.File, from, to int) error {
+	for i := from; i < to; i++ {
+		var err error
+		if i < len(f.Annotations) {
+			err = lt.WriteAnnotatedLine(f.Lines[i], f.Annotations[i], f.AnnotationSuffix)
+		} else {
+			err = lt.WriteLine(f.Lines[i])
+		}
+		if err != nil {
 			return err
 		}
 	}
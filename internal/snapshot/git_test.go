@@ -0,0 +1,253 @@
+package snapshot_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/neox5/snp/internal/file"
+	"github.com/neox5/snp/internal/snapshot"
+)
+
+// gitRepo commits files to a new repository and returns its directory
+func gitRepo(t *testing.T, files map[string]string) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := writeTree(t, files)
+	git(t, dir, "init", "-q")
+	git(t, dir, "add", "-A")
+	git(t, dir, "commit", "-q", "-m", "initial")
+	return dir
+}
+
+// git runs a git command in dir
+func git(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com",
+		"GIT_CONFIG_GLOBAL=/dev/null", "GIT_CONFIG_NOSYSTEM=1",
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+}
+
+// appendFile appends text to a file below dir
+func appendFile(t *testing.T, dir, name, text string) {
+	t.Helper()
+	f, err := os.OpenFile(filepath.Join(dir, name), os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(text); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWorkTree_ExcludedFiles(t *testing.T) {
+	dir := gitRepo(t, map[string]string{
+		".gitignore":  "*.log\n",
+		"main.go":     "package main\n",
+		"private.cfg": "token=old\n",
+		"notes.txt":   "notes\n",
+	})
+	appendFile(t, dir, "main.go", "// staged\n")
+	appendFile(t, dir, "private.cfg", "token=staged\n")
+	git(t, dir, "add", "main.go", "private.cfg")
+	appendFile(t, dir, "notes.txt", "unstaged\n")
+	appendFile(t, dir, "private.cfg", "token=unstaged\n")
+	if err := os.WriteFile(filepath.Join(dir, "new.cfg"), []byte("secret\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, output := build(t, snapshot.Config{
+		SourceDir:       dir,
+		IncludeGitDiff:  true,
+		ExcludePatterns: []string{"*.cfg"},
+	})
+
+	for _, title := range []string{"Git Status (git status --short)", "Git Diff Staged (git diff --cached)", "Git Diff Unstaged (git diff)"} {
+		lines := section(output, title)
+		if lines == nil {
+			t.Fatalf("missing section %q", title)
+		}
+		text := strings.Join(lines, "\n")
+		if strings.Contains(text, ".cfg") || strings.Contains(text, "token=") {
+			t.Errorf("%s leaks excluded files:\n%s", title, text)
+		}
+	}
+	if got := strings.Join(section(output, "Git Diff Staged (git diff --cached)"), "\n"); !strings.Contains(got, "+// staged") {
+		t.Errorf("staged diff lacks main.go:\n%s", got)
+	}
+	if got := strings.Join(section(output, "Git Diff Unstaged (git diff)"), "\n"); !strings.Contains(got, "+unstaged") {
+		t.Errorf("unstaged diff lacks notes.txt:\n%s", got)
+	}
+}
+
+func TestChanges_ExcludedFiles(t *testing.T) {
+	dir := gitRepo(t, map[string]string{
+		"main.go":     "package main\n",
+		"private.cfg": "token=old\n",
+	})
+	git(t, dir, "tag", "base")
+	appendFile(t, dir, "main.go", "// changed\n")
+	appendFile(t, dir, "private.cfg", "token=new\n")
+	git(t, dir, "commit", "-q", "-am", "change")
+
+	snap, output := build(t, snapshot.Config{
+		SourceDir:       dir,
+		ChangedSince:    "base",
+		ExcludePatterns: []string{"private.cfg"},
+	})
+
+	if len(snap.Files) != 1 || snap.Files[0].RelPath != "main.go" {
+		t.Fatalf("collected %d files, want main.go only", len(snap.Files))
+	}
+	var lines []string
+	for _, line := range strings.Split(output, "\n") {
+		if strings.HasPrefix(line, "# Changes since base") {
+			lines = section(output, strings.TrimPrefix(line, "# "))
+		}
+	}
+	text := strings.Join(lines, "\n")
+	if !strings.Contains(text, "+// changed") {
+		t.Errorf("changes lack main.go:\n%s", text)
+	}
+	if strings.Contains(text, "private.cfg") || strings.Contains(text, "token=") {
+		t.Errorf("changes leak excluded file:\n%s", text)
+	}
+}
+
+func TestBlame_WarnsUnannotated(t *testing.T) {
+	dir := gitRepo(t, map[string]string{"main.go": "package main\n"})
+	if err := os.WriteFile(filepath.Join(dir, "new.go"), []byte("package main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	snap, output := build(t, snapshot.Config{SourceDir: dir, Blame: true, BlameSuffix: true})
+	if len(snap.Warnings) != 1 || !strings.HasPrefix(snap.Warnings[0], "new.go not annotated: ") {
+		t.Errorf("warnings = %q, want one for new.go", snap.Warnings)
+	}
+	if !strings.Contains(output, "\npackage main | ") {
+		t.Error("main.go not annotated")
+	}
+}
+
+func TestGitMeta_IndexAttrs(t *testing.T) {
+	dir := gitRepo(t, map[string]string{
+		"main.go": "package main\n",
+		"util.go": "package main\n",
+	})
+	appendFile(t, dir, "main.go", "// second\n")
+	git(t, dir, "commit", "-q", "-am", "second")
+	appendFile(t, dir, "util.go", "// unstaged\n")
+	if err := os.WriteFile(filepath.Join(dir, "new.go"), []byte("package main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, output := build(t, snapshot.Config{SourceDir: dir, IncludeGitMeta: true})
+	entries := make(map[string]string)
+	for _, line := range section(output, "File Index") {
+		name, attrs, _ := strings.Cut(line, " ")
+		entries[name] = attrs
+	}
+
+	tests := []struct {
+		name  string
+		attrs string
+	}{
+		{"main.go", "(tracked, "},
+		{"util.go", "(modified, "},
+		{"new.go", "(untracked, 1 lines"},
+	}
+	for _, tt := range tests {
+		attrs, ok := entries[tt.name]
+		if !ok {
+			t.Fatalf("no index entry for %s", tt.name)
+		}
+		if _, rest, _ := strings.Cut(attrs, "] "); !strings.HasPrefix(rest, tt.attrs) {
+			t.Errorf("%s attributes = %q, want prefix %q", tt.name, rest, tt.attrs)
+		}
+	}
+	if !strings.Contains(entries["main.go"], " test, 2 commits, ") {
+		t.Errorf("main.go lacks last commit and count: %q", entries["main.go"])
+	}
+	if !strings.Contains(entries["util.go"], " test, 1 commit, ") {
+		t.Errorf("util.go lacks last commit and count: %q", entries["util.go"])
+	}
+}
+
+// submoduleRepo returns a superproject with a "lib" submodule whose own
+// .gitignore ignores *.tmp, and the commit the submodule is pinned to
+func submoduleRepo(t *testing.T) (string, string) {
+	t.Helper()
+	lib := gitRepo(t, map[string]string{
+		".gitignore": "*.tmp\n",
+		"lib.go":     "package lib\n",
+	})
+	out, err := exec.Command("git", "-C", lib, "rev-parse", "HEAD").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := gitRepo(t, map[string]string{"main.go": "package main\n"})
+	git(t, dir, "-c", "protocol.file.allow=always", "submodule", "add", "-q", lib, "lib")
+	git(t, dir, "commit", "-q", "-m", "add lib")
+	if err := os.WriteFile(filepath.Join(dir, "lib", "build.tmp"), []byte("output\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "lib", ".git")); err != nil {
+		t.Fatal(err)
+	}
+	return dir, strings.TrimSpace(string(out))
+}
+
+func TestSubmodules_Collapse(t *testing.T) {
+	dir, pinned := submoduleRepo(t)
+
+	snap, output := build(t, snapshot.Config{SourceDir: dir, IncludeTree: true})
+	for _, f := range snap.Files {
+		if strings.HasPrefix(f.RelPath, "lib/") {
+			t.Errorf("collected %s from a collapsed submodule", f.RelPath)
+		}
+	}
+	index := section(output, "File Index")
+	want := "lib/ (submodule " + pinned[:7] + ", collapsed)"
+	if !slices.Contains(index, want) {
+		t.Errorf("index = %q, want entry %q", index, want)
+	}
+	tree := strings.Join(section(output, "Directory Tree"), "\n")
+	if !strings.Contains(tree, "lib/ (submodule "+pinned[:7]+")") {
+		t.Errorf("tree lacks the pinned commit:\n%s", tree)
+	}
+}
+
+func TestSubmodules_Recurse(t *testing.T) {
+	dir, _ := submoduleRepo(t)
+
+	snap, output := build(t, snapshot.Config{SourceDir: dir, Submodules: file.SubmodulesRecurse})
+	var paths []string
+	for _, f := range snap.Files {
+		paths = append(paths, f.RelPath)
+	}
+	if !slices.Contains(paths, "lib/lib.go") {
+		t.Errorf("files = %q, want lib/lib.go", paths)
+	}
+	if slices.Contains(paths, "lib/build.tmp") {
+		t.Error("submodule .gitignore not applied")
+	}
+	for _, line := range section(output, "File Index") {
+		if strings.HasPrefix(line, "lib/ ") {
+			t.Errorf("recursed submodule listed as collapsed: %q", line)
+		}
+	}
+}
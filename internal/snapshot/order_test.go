@@ -0,0 +1,108 @@
+package snapshot_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/neox5/snp/internal/file"
+	"github.com/neox5/snp/internal/snapshot"
+)
+
+func TestOrderFiles(t *testing.T) {
+	dir := writeTree(t, map[string]string{
+		"README.md":          strings.Repeat("r", 300),
+		"main.go":            strings.Repeat("m", 50),
+		"cmd/tool/tool.go":   strings.Repeat("t", 10),
+		"internal/a/a.go":    strings.Repeat("a", 50),
+		"internal/b.go":      strings.Repeat("b", 50),
+		"docs/guide.md":      strings.Repeat("g", 200),
+		"docs/api/spec.yaml": strings.Repeat("s", 10),
+	})
+
+	// Modification times for recency, newest first: b.go, guide.md, rest
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, name := range []string{"README.md", "main.go", "cmd/tool/tool.go", "internal/a/a.go", "docs/api/spec.yaml"} {
+		setModTime(t, filepath.Join(dir, name), base.Add(time.Duration(i)*time.Minute))
+	}
+	setModTime(t, filepath.Join(dir, "docs/guide.md"), base.Add(time.Hour))
+	setModTime(t, filepath.Join(dir, "internal/b.go"), base.Add(2*time.Hour))
+
+	tests := []struct {
+		name     string
+		sortBy   string
+		priority []string
+		want     []string
+	}{
+		{
+			name:   "default is walk order",
+			sortBy: "",
+			want:   []string{"README.md", "cmd/tool/tool.go", "docs/api/spec.yaml", "docs/guide.md", "internal/a/a.go", "internal/b.go", "main.go"},
+		},
+		{
+			name:   "path",
+			sortBy: file.SortPath,
+			want:   []string{"README.md", "cmd/tool/tool.go", "docs/api/spec.yaml", "docs/guide.md", "internal/a/a.go", "internal/b.go", "main.go"},
+		},
+		{
+			// Equal sizes keep walk order
+			name:   "size",
+			sortBy: file.SortSize,
+			want:   []string{"cmd/tool/tool.go", "docs/api/spec.yaml", "internal/a/a.go", "internal/b.go", "main.go", "docs/guide.md", "README.md"},
+		},
+		{
+			name:   "recency",
+			sortBy: file.SortRecency,
+			want:   []string{"internal/b.go", "docs/guide.md", "docs/api/spec.yaml", "internal/a/a.go", "cmd/tool/tool.go", "main.go", "README.md"},
+		},
+		{
+			// Equal depths keep walk order
+			name:   "depth",
+			sortBy: file.SortDepth,
+			want:   []string{"README.md", "main.go", "docs/guide.md", "internal/b.go", "cmd/tool/tool.go", "docs/api/spec.yaml", "internal/a/a.go"},
+		},
+		{
+			name:     "priority groups before sort",
+			sortBy:   file.SortSize,
+			priority: []string{"*.md", "internal/"},
+			want:     []string{"docs/guide.md", "README.md", "internal/a/a.go", "internal/b.go", "cmd/tool/tool.go", "docs/api/spec.yaml", "main.go"},
+		},
+		{
+			name:     "first matching pattern wins",
+			priority: []string{"docs/", "*.md"},
+			want:     []string{"docs/api/spec.yaml", "docs/guide.md", "README.md", "cmd/tool/tool.go", "internal/a/a.go", "internal/b.go", "main.go"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snap, _ := build(t, snapshot.Config{SourceDir: dir, SortBy: tt.sortBy, PriorityPatterns: tt.priority})
+			var got []string
+			for _, f := range snap.Files {
+				got = append(got, f.RelPath)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("order = %q\nwant    %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOrderFiles_UnknownSort(t *testing.T) {
+	dir := writeTree(t, map[string]string{"a.txt": "a"})
+	cfg := snapshot.Config{SourceDir: dir, SortBy: "random"}
+	if _, err := snapshot.Build(t.Context(), cfg, dir, filepath.Join(dir, "out.snp")); err == nil {
+		t.Error("unknown sort strategy accepted")
+	}
+}
+
+// setModTime sets the modification time of path
+func setModTime(t *testing.T, path string, mtime time.Time) {
+	t.Helper()
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+}
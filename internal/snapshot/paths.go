@@ -2,17 +2,28 @@ package snapshot
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 // DefaultOutputName is the default snapshot output filename
 const DefaultOutputName = "snapshot.snp"
 
+// Stdout as output path writes the snapshot to standard output
+const Stdout = "-"
+
+// gzipExt marks compressed snapshots
+const gzipExt = ".gz"
+
 func resolveOutputPath(outputPath string) (absOutput string, err error) {
 	if outputPath == "" {
 		outputPath = DefaultOutputName
 	}
+	if outputPath == Stdout {
+		return Stdout, nil
+	}
 
 	absOutput, err = filepath.Abs(outputPath)
 	if err != nil {
@@ -43,6 +54,38 @@ func ValidateAndResolve(cfg Config) (absSourceDir, absOutput string, err error)
 	if err != nil {
 		return "", "", err
 	}
+	if absOutput == Stdout && cfg.SplitSize.Limit > 0 {
+		return "", "", fmt.Errorf("split snapshots cannot be written to stdout")
+	}
+	if cfg.Compress && absOutput != Stdout && !strings.HasSuffix(absOutput, gzipExt) {
+		absOutput += gzipExt
+	}
 
 	return absSourceDir, absOutput, nil
 }
+
+// WriteFile writes a snapshot or part to path. The content goes to a
+// temporary file next to path that then replaces it, so readers never see
+// a partially written snapshot.
+func WriteFile(path string, w io.WriterTo) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("cannot create output file %q: %w", path, err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if _, err := w.WriteTo(tmp); err != nil {
+		return err
+	}
+	if err := tmp.Chmod(0o644); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("cannot replace output file %q: %w", path, err)
+	}
+	return nil
+}
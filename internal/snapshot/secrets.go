@@ -0,0 +1,78 @@
+package snapshot
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/neox5/snp/internal/secrets"
+)
+
+// SecretsError aborts a snapshot that would contain secrets
+type SecretsError struct {
+	Findings []secrets.Finding
+}
+
+func (e *SecretsError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "found %s", formatCount(len(e.Findings), "secret", "secrets"))
+	for _, f := range e.Findings {
+		fmt.Fprintf(&b, "\n  %s:%d %s", f.Path, f.Line, f.Rule)
+	}
+	return b.String()
+}
+
+// redactSecrets replaces secrets in text files and diff sections with
+// placeholders and records the findings
+func (s *Snapshot) redactSecrets() {
+	scanner := secrets.NewScanner()
+
+	for _, f := range s.Files {
+		if f.IsBinary {
+			continue
+		}
+		found := scanner.Redact(f.RelPath, f.Lines)
+		f.Secrets = len(found)
+		s.Secrets = append(s.Secrets, found...)
+	}
+
+	// Diffs repeat file content, including content of excluded files
+	if s.WorkTree != nil {
+		s.Secrets = append(s.Secrets, scanner.RedactDiff("staged diff", s.WorkTree.StagedDiff)...)
+		s.Secrets = append(s.Secrets, scanner.RedactDiff("unstaged diff", s.WorkTree.UnstagedDiff)...)
+	}
+	if s.Changes != nil {
+		for _, p := range s.Changes.Order {
+			s.Secrets = append(s.Secrets, scanner.RedactDiff("changes", s.Changes.Diffs[p])...)
+		}
+	}
+}
+
+// applyRedactRules applies user-defined redaction rules to text files, the
+// git log and diff sections
+func (s *Snapshot) applyRedactRules(rules []secrets.Rule) {
+	for _, f := range s.Files {
+		if !f.IsBinary {
+			f.Redactions = secrets.ApplyRules(rules, f.RelPath, f.Lines)
+		}
+	}
+
+	secrets.ApplyRules(rules, "", s.GitLogLines)
+	if s.WorkTree != nil {
+		secrets.ApplyRulesDiff(rules, s.WorkTree.StagedDiff)
+		secrets.ApplyRulesDiff(rules, s.WorkTree.UnstagedDiff)
+	}
+	if s.Changes != nil {
+		for _, p := range s.Changes.Order {
+			secrets.ApplyRulesDiff(rules, s.Changes.Diffs[p])
+		}
+	}
+}
+
+// secretLines lists the redacted secrets for the preamble
+func (s *Snapshot) secretLines() []string {
+	lines := make([]string, len(s.Secrets))
+	for i, f := range s.Secrets {
+		lines[i] = f.String()
+	}
+	return lines
+}
@@ -1,21 +1,62 @@
 package snapshot
 
 import (
+	"compress/gzip"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
+	"github.com/neox5/snp/internal/cache"
 	"github.com/neox5/snp/internal/file"
 	"github.com/neox5/snp/internal/gitlog"
+	"github.com/neox5/snp/internal/ignore"
+	"github.com/neox5/snp/internal/secrets"
+	"github.com/neox5/snp/internal/stats"
+	"github.com/neox5/snp/internal/token"
+	"github.com/neox5/snp/internal/version"
 	"github.com/neox5/snp/internal/writer"
 )
 
 // Snapshot represents the complete snapshot data
 type Snapshot struct {
-	GitLogLines GitLogLines
-	Files       []*file.File
-	Layout      []Content
+	GitLogLines  GitLogLines
+	GitLogTitle  string
+	WorkTree     *gitlog.WorkTree
+	Changes      *gitlog.Changes
+	Revision     *gitlog.Revision
+	Submodules   []gitlog.Submodule // Nested repositories below the source directory
+	Files        []*file.File
+	ExcludedDirs []string
+	Cuts         []Cut
+	Secrets      []secrets.Finding // Secrets replaced by placeholders
+	Layout       []Content
+	Parts        []*Part  // Set when the snapshot is split into numbered parts
+	Warnings     []string // Problems that did not stop the build
+
+	preamble []Content
+	sections [][]Content
+
+	timestamp    string
+	tokenizer    token.Tokenizer
+	maxTokens    int
+	splitSize    SplitSize
+	includeTree  bool
+	includeStats bool
+	recurseSubs  bool
+	options      string
+	hashes       bool
+	compress     bool
+	digest       *string // Set when the summary records a digest
+	totalLines   int
+	totalTokens  int
+	totalParts   int
 }
 
 // GitLogLines represents git log output
@@ -23,110 +64,811 @@ type GitLogLines []string
 
 // Build creates a complete snapshot
 func Build(ctx context.Context, cfg Config, absSourceDir string, absOutput string) (*Snapshot, error) {
-	snap := &Snapshot{}
+	tok, err := token.New(cfg.Tokenizer)
+	if err != nil {
+		return nil, err
+	}
+
+	snap := &Snapshot{
+		timestamp:    time.Now().Format("2006-01-02 15:04:05"),
+		tokenizer:    tok,
+		maxTokens:    cfg.MaxTokens,
+		splitSize:    cfg.SplitSize,
+		includeTree:  cfg.IncludeTree || cfg.TreeExcluded,
+		includeStats: cfg.IncludeStats,
+		recurseSubs:  cfg.Submodules == file.SubmodulesRecurse,
+		options:      strings.Join(cfg.Options, " "),
+		hashes:       cfg.IncludeHashes,
+		compress:     cfg.Compress || strings.HasSuffix(absOutput, gzipExt),
+	}
+
+	// The digest covers the whole output, which split snapshots spread
+	// over several files
+	if cfg.IncludeHashes && cfg.SplitSize.Limit == 0 {
+		placeholder := strings.Repeat("0", sha256.Size*2)
+		snap.digest = &placeholder
+	}
 
 	// Collect git log if enabled
 	if cfg.IncludeGitLog && gitlog.HasRepo(absSourceDir) {
-		gitLogData, err := gitlog.Collect(ctx, absSourceDir)
+		gitLogData, err := gitlog.Collect(ctx, absSourceDir, cfg.GitLogOptions)
 		if err != nil {
 			return nil, fmt.Errorf("failed to collect git log: %w", err)
 		}
 		snap.GitLogLines = gitLogData.Lines
+		snap.GitLogTitle = "Git Log (" + cfg.GitLogOptions.Describe() + ")"
 	}
 
-	// Collect and load files
-	files, textFiles, binaryFiles, err := file.Collect(
-		absSourceDir,
-		absOutput,
-		cfg.ExcludePatterns,
-		cfg.IncludePatterns,
-		cfg.ForceTextPatterns,
-		cfg.ForceBinaryPatterns,
-	)
+	// Collect working tree changes if enabled
+	if cfg.IncludeGitDiff && gitlog.HasRepo(absSourceDir) {
+		wt, err := gitlog.CollectWorkTree(ctx, absSourceDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to collect working tree changes: %w", err)
+		}
+		snap.WorkTree = wt
+	}
+
+	collectOpts := cfg.CollectOptions(absSourceDir, absOutput)
+
+	// Restrict to files changed since a ref if requested
+	if cfg.ChangedSince != "" {
+		if !gitlog.HasRepo(absSourceDir) {
+			return nil, fmt.Errorf("--changed-since requires a git repository")
+		}
+		changes, err := gitlog.CollectChanges(ctx, absSourceDir, cfg.ChangedSince)
+		if err != nil {
+			return nil, fmt.Errorf("failed to collect changes: %w", err)
+		}
+		snap.Changes = changes
+		collectOpts.Filter = func(relPath string) bool {
+			return changes.Touches(relPath, cfg.WithNeighbors)
+		}
+	}
+
+	// Reuse detection results and token estimates of unchanged files
+	var contentCache *cache.Cache
+	if cfg.CacheDir != "" && cfg.Rev == "" {
+		key := version.String() + " " + cfg.Tokenizer + " " + snap.options
+		contentCache = cache.Open(cache.Path(cfg.CacheDir, absSourceDir), key)
+		collectOpts.Cache = contentCache
+	}
+
+	// Collect and load files, from a git revision if requested
+	var files []*file.File
+	if cfg.Rev != "" {
+		files, err = snap.collectRevision(ctx, cfg, absSourceDir, collectOpts)
+	} else {
+		files, _, _, err = file.Collect(collectOpts)
+	}
 	if err != nil {
 		return nil, err
 	}
 	snap.Files = files
 
-	// Prepare summary metadata
-	timestamp := time.Now().Format("2006-01-02 15:04:05")
-	totalFiles := len(files)
-	totalLines := 0 // Will be set after layout construction
+	// git reports changes of the whole tree; keep those of included files
+	if snap.WorkTree != nil || snap.Changes != nil {
+		keep, err := snap.includedPath(absSourceDir, collectOpts)
+		if err != nil {
+			return nil, err
+		}
+		if snap.WorkTree != nil {
+			snap.WorkTree.Restrict(keep)
+		}
+		if snap.Changes != nil {
+			snap.Changes.Restrict(keep)
+		}
+	}
+
+	// Make suspicious characters visible; redaction rules then see the
+	// text as it is written
+	if cfg.EscapeUnicode {
+		snap.escapeUnicode()
+	}
+
+	// Redact content before anything is derived from it
+	if len(cfg.RedactRules) > 0 {
+		snap.applyRedactRules(cfg.RedactRules)
+	}
+	if !cfg.KeepSecrets {
+		snap.redactSecrets()
+		if cfg.FailOnSecrets && len(snap.Secrets) > 0 {
+			return nil, &SecretsError{Findings: snap.Secrets}
+		}
+	}
+
+	// Detect submodules and other nested repositories
+	if cfg.Rev == "" {
+		dirs, err := file.NestedRepos(collectOpts)
+		if err != nil {
+			return nil, err
+		}
+		if snap.Submodules, err = gitlog.CollectSubmodules(absSourceDir, dirs); err != nil {
+			return nil, fmt.Errorf("failed to read submodules: %w", err)
+		}
+	}
+
+	// Mark changed files and their neighbors
+	if snap.Changes != nil {
+		for _, f := range snap.Files {
+			if snap.Changes.Paths[f.RelPath] {
+				f.ChangeMark = "changed"
+			} else {
+				f.ChangeMark = "neighbor"
+			}
+		}
+	}
+
+	// Mark files with uncommitted changes
+	if snap.WorkTree != nil {
+		for _, f := range snap.Files {
+			f.GitStatus = snap.WorkTree.FileStatus[f.RelPath]
+		}
+	}
+
+	// Annotate files with git metadata
+	if cfg.IncludeGitMeta && gitlog.HasRepo(absSourceDir) && snap.Revision == nil {
+		if err := annotateGitMeta(ctx, absSourceDir, snap.Files); err != nil {
+			return nil, err
+		}
+	}
+
+	// Annotate text lines with blame information
+	if cfg.Blame || cfg.BlameSuffix {
+		if !gitlog.HasRepo(absSourceDir) {
+			return nil, fmt.Errorf("--blame requires a git repository")
+		}
+		rev := ""
+		if snap.Revision != nil {
+			rev = snap.Revision.Commit
+		}
+		warnings, err := annotateBlame(ctx, absSourceDir, rev, snap.Files, cfg.BlameSuffix)
+		if err != nil {
+			return nil, err
+		}
+		snap.Warnings = append(snap.Warnings, warnings...)
+	}
+
+	// Collect excluded directories for the tree view
+	if cfg.TreeExcluded {
+		dirs, err := file.ExcludedDirs(collectOpts, files)
+		if err != nil {
+			return nil, err
+		}
+		snap.ExcludedDirs = dirs
+	}
+
+	// Hash binary files, whose content is not loaded
+	if cfg.IncludeHashes {
+		for _, f := range snap.Files {
+			if err := f.ComputeHash(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Order files by priority; the order also decides what budget cuts drop
+	if err := orderFiles(ctx, cfg, absSourceDir, snap.Files); err != nil {
+		return nil, err
+	}
+
+	// Estimate tokens per file. Annotated lines depend on git history and
+	// redacted lines on the rules and the other files, not only on
+	// content, so their estimates are not cached.
+	for _, f := range snap.Files {
+		cacheable := contentCache != nil && !f.IsBinary && len(f.Annotations) == 0 &&
+			f.Secrets == 0 && f.Redactions == 0
+		if cacheable {
+			if n, ok := contentCache.Tokens(f.RelPath, f.Hash); ok {
+				f.Tokens = n
+				continue
+			}
+		}
+		f.Tokens = token.CountLines(tok, f.OutputLines())
+		if cacheable {
+			contentCache.SetTokens(f.RelPath, f.Hash, f.Tokens)
+		}
+	}
+
+	// The cache only saves work; failing to write it is not fatal
+	if contentCache != nil {
+		_ = contentCache.Save()
+	}
+
+	snap.buildLayout()
+
+	// Fit token budget (drops or truncates lowest-priority files)
+	if cfg.MaxTokens > 0 && snap.totalTokens > cfg.MaxTokens {
+		snap.fitBudget()
+		snap.buildLayout()
+
+		// The estimate misses layout changes such as the budget report
+		// header; drop further files until the rendered layout fits
+		for snap.totalTokens > cfg.MaxTokens && len(snap.Files) > 0 {
+			snap.dropLast()
+			snap.buildLayout()
+		}
+	}
+
+	// Split into numbered parts if requested
+	if cfg.SplitSize.Limit > 0 {
+		if err := snap.split(); err != nil {
+			return nil, err
+		}
+	}
+
+	if snap.digest != nil {
+		digest, err := snap.computeDigest()
+		if err != nil {
+			return nil, err
+		}
+		*snap.digest = digest
+	}
+
+	return snap, nil
+}
+
+// includedPath reports whether changes of a path belong in the snapshot:
+// those of collected files do, and those of deleted files unless the
+// ignore rules exclude them
+func (s *Snapshot) includedPath(absSourceDir string, opts file.CollectOptions) (func(relPath string) bool, error) {
+	collected := make(map[string]bool, len(s.Files))
+	for _, f := range s.Files {
+		collected[f.RelPath] = true
+	}
+	matchers, err := ignore.NewMatchers(absSourceDir, opts.ExcludePatterns, opts.IncludePatterns)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(relPath string) bool {
+		if collected[relPath] {
+			return true
+		}
+		if relPath == "" {
+			return false
+		}
+		if _, err := os.Lstat(filepath.Join(absSourceDir, filepath.FromSlash(relPath))); err == nil {
+			return false
+		}
+		return matchers.ShouldInclude(relPath) && (opts.Filter == nil || opts.Filter(relPath))
+	}, nil
+}
+
+// collectRevision collects files from the object store at cfg.Rev instead
+// of the working tree and uses the commit time as snapshot timestamp
+func (s *Snapshot) collectRevision(ctx context.Context, cfg Config, absSourceDir string, opts file.CollectOptions) ([]*file.File, error) {
+	if !gitlog.HasRepo(absSourceDir) {
+		return nil, fmt.Errorf("--rev requires a git repository")
+	}
+	if cfg.IncludeGitDiff || cfg.ChangedSince != "" {
+		return nil, fmt.Errorf("--rev cannot be combined with --git-diff or --changed-since")
+	}
+
+	rev, err := gitlog.ResolveRevision(ctx, absSourceDir, cfg.Rev)
+	if err != nil {
+		return nil, err
+	}
+	s.Revision = rev
+	s.timestamp = rev.Time.Format("2006-01-02 15:04:05")
+
+	entries, err := gitlog.ListTree(ctx, absSourceDir, rev.Commit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tree of %q: %w", cfg.Rev, err)
+	}
+
+	blobs, err := gitlog.NewBlobReader(ctx, absSourceDir)
+	if err != nil {
+		return nil, err
+	}
+	defer blobs.Close()
+
+	gitignore, _, err := blobs.Read(rev.Commit + ":./.gitignore")
+	if err != nil {
+		return nil, err
+	}
+
+	objects := make(map[string]string, len(entries))
+	tree := make([]file.TreeFile, len(entries))
+	for i, e := range entries {
+		objects[e.Path] = e.Object
+		tree[i] = file.TreeFile{RelPath: e.Path, Size: e.Size}
+	}
+
+	files, _, _, err := file.CollectTree(opts, gitignore, tree, func(relPath string) ([]byte, error) {
+		content, ok, err := blobs.Read(objects[relPath])
+		if err == nil && !ok {
+			err = fmt.Errorf("object %s missing", objects[relPath])
+		}
+		return content, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+// annotateGitMeta sets last commit, commit count and tracking status on
+// files using one git log and one git status invocation
+func annotateGitMeta(ctx context.Context, absSourceDir string, files []*file.File) error {
+	history, err := gitlog.CollectHistory(ctx, absSourceDir)
+	if err != nil {
+		return fmt.Errorf("failed to collect file history: %w", err)
+	}
+	// Without the git binary the index cannot be compared; history still works
+	status, err := gitlog.FileStatus(ctx, absSourceDir)
+	if errors.Is(err, gitlog.ErrNoGit) {
+		status = nil
+	} else if err != nil {
+		return fmt.Errorf("failed to collect file status: %w", err)
+	}
+
+	for _, f := range files {
+		if h, ok := history[f.RelPath]; ok {
+			f.Git = &file.GitInfo{
+				Commit:  h.Commit,
+				Date:    h.Time.Format("2006-01-02"),
+				Author:  h.Author,
+				Commits: h.Commits,
+			}
+		}
+
+		switch {
+		case status[f.RelPath] != "":
+			f.GitStatus = status[f.RelPath]
+		case f.Git != nil && status != nil:
+			f.GitStatus = "tracked"
+		}
+	}
+
+	return nil
+}
+
+// maxBlameAuthorWidth caps the author column of blame annotations
+const maxBlameAuthorWidth = 16
+
+// annotateBlame prefixes (or suffixes) every text line with the commit and
+// author that last touched it. Files git cannot blame, such as untracked
+// files, are left unannotated with a warning each.
+func annotateBlame(ctx context.Context, absSourceDir, rev string, files []*file.File, suffix bool) ([]string, error) {
+	var warnings []string
+	for _, f := range files {
+		if f.IsBinary {
+			continue
+		}
+
+		blame, err := gitlog.Blame(ctx, absSourceDir, rev, f.RelPath)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			if errors.Is(err, gitlog.ErrNoGit) {
+				return nil, fmt.Errorf("--blame: %w", err)
+			}
+			warnings = append(warnings, fmt.Sprintf("%s not annotated: %v", f.RelPath, err))
+			continue
+		}
+		if len(blame) != len(f.Lines) {
+			warnings = append(warnings, fmt.Sprintf("%s not annotated: blame has %d lines, file has %d", f.RelPath, len(blame), len(f.Lines)))
+			continue
+		}
+
+		width := 0
+		for _, b := range blame {
+			width = max(width, min(len([]rune(b.Author)), maxBlameAuthorWidth))
+		}
+
+		f.Annotations = make([]string, len(blame))
+		f.AnnotationSuffix = suffix
+		for i, b := range blame {
+			commit := b.Commit
+			if commit == "" {
+				commit = "--------"
+			}
+			author := []rune(b.Author)
+			if len(author) > maxBlameAuthorWidth {
+				author = author[:maxBlameAuthorWidth]
+			}
+			f.Annotations[i] = fmt.Sprintf("%s %-*s", commit, width, string(author))
+		}
+	}
+
+	return warnings, nil
+}
+
+// orderFiles applies priority patterns and the sort strategy
+func orderFiles(ctx context.Context, cfg Config, absSourceDir string, files []*file.File) error {
+	if err := file.ValidateSort(cfg.SortBy); err != nil {
+		return err
+	}
+
+	opts := file.SortOptions{
+		PriorityPatterns: cfg.PriorityPatterns,
+		By:               cfg.SortBy,
+	}
+
+	if cfg.SortBy == file.SortRecency && gitlog.HasRepo(absSourceDir) {
+		history, err := gitlog.CollectHistory(ctx, absSourceDir)
+		if err != nil {
+			return fmt.Errorf("failed to collect file commit times: %w", err)
+		}
+		opts.CommitTimes = make(map[string]time.Time, len(history))
+		for path, h := range history {
+			opts.CommitTimes[path] = h.Time
+		}
+	}
+
+	file.Sort(files, opts)
+	return nil
+}
+
+// buildLayout constructs the content layout from the snapshot data,
+// assigns file start lines and computes line and token totals
+func (s *Snapshot) buildLayout() {
+	s.preamble = s.buildPreamble()
+
+	s.sections = nil
+	for _, f := range s.Files {
+		s.sections = append(s.sections, []Content{
+			newHeader(f.RelPath),
+			newFileContent(f),
+		})
+	}
+
+	layout := append([]Content(nil), s.preamble...)
+	for i, section := range s.sections {
+		layout = append(layout, section...)
+
+		// Add spacing only if not the last file
+		if i < len(s.sections)-1 {
+			layout = append(layout, sectionSpacing()...)
+		}
+	}
+
+	s.totalLines = assignLines(layout, 0)
+	s.totalTokens = countTokens(layout, s.tokenizer)
+	s.Layout = layout
+}
+
+// buildPreamble constructs everything before the first file section
+func (s *Snapshot) buildPreamble() []Content {
+	var textFiles, binaryFiles int
+	for _, f := range s.Files {
+		if f.IsBinary {
+			binaryFiles++
+		} else {
+			textFiles++
+		}
+	}
+
+	var statsLines []string
+	if s.includeStats {
+		statsLines = stats.Compute(s.Files, stats.DefaultTopN).Lines()
+	}
+
+	revision := ""
+	if s.Revision != nil {
+		revision = fmt.Sprintf("%s (%s)", s.Revision.Name, s.Revision.Commit[:12])
+	}
 
-	// Build layout (single pass)
 	var layout []Content
 
-	// Summary section (with mutable totalLines pointer)
+	// Summary section (totals are filled in after layout construction)
 	layout = append(layout,
-		newSummary(timestamp, totalFiles, textFiles, binaryFiles, &totalLines),
+		summary{
+			Timestamp:   s.timestamp,
+			TotalFiles:  len(s.Files),
+			TextFiles:   textFiles,
+			BinaryFiles: binaryFiles,
+			TotalLines:  &s.totalLines,
+			TotalTokens: &s.totalTokens,
+			Tokenizer:   s.tokenizer.Name(),
+			MaxTokens:   s.maxTokens,
+			SplitSize:   s.splitSize,
+			TotalParts:  &s.totalParts,
+			Stats:       statsLines,
+			Revision:    revision,
+			Options:     s.options,
+			Digest:      s.digest,
+			Unicode:     s.UnicodeSummary(),
+		},
 		newEmptyLine(),
 	)
 
 	// Index section
 	layout = append(layout,
 		newHeader("File Index"),
-		newIndex(snap.Files),
+		newIndex(s.Files, s.collapsedEntries(), s.hashes),
 		newEmptyLine(),
 		newSeparator(),
 		newEmptyLine(),
 	)
 
+	// Directory tree section (if enabled)
+	if s.includeTree {
+		layout = append(layout,
+			newHeader("Directory Tree"),
+			newTree(s.Files, s.ExcludedDirs, s.collapsedSubmodules()),
+			newEmptyLine(),
+			newSeparator(),
+			newEmptyLine(),
+		)
+	}
+
+	// Token budget section (if anything was cut)
+	if len(s.Cuts) > 0 {
+		layout = append(layout,
+			newHeader("Token Budget"),
+			newBudgetReport(s.Cuts),
+			newEmptyLine(),
+			newSeparator(),
+			newEmptyLine(),
+		)
+	}
+
+	// Redacted secrets section (if any were found)
+	if len(s.Secrets) > 0 {
+		layout = append(layout,
+			newHeader("Redacted Secrets"),
+			newLineBlock(s.secretLines()),
+			newEmptyLine(),
+			newSeparator(),
+			newEmptyLine(),
+		)
+	}
+
 	// Git log section (if present)
-	if len(snap.GitLogLines) > 0 {
+	if len(s.GitLogLines) > 0 {
 		layout = append(layout,
-			newHeader("Git Log (git adog)"),
-			newGitLog(snap.GitLogLines),
+			newHeader(s.GitLogTitle),
+			newLineBlock(s.GitLogLines),
 			newEmptyLine(),
 			newSeparator(),
 			newEmptyLine(),
 		)
 	}
 
-	// File contents sections
-	for i, f := range snap.Files {
+	// Submodules section (with the git log)
+	if len(s.GitLogLines) > 0 && len(s.Submodules) > 0 {
 		layout = append(layout,
-			newHeader(f.RelPath),
-			newFileContent(f),
+			newHeader("Submodules"),
+			newLineBlock(s.submoduleLines()),
+			newEmptyLine(),
+			newSeparator(),
+			newEmptyLine(),
 		)
+	}
 
-		// Add spacing only if not the last file
-		if i < len(snap.Files)-1 {
-			layout = append(layout,
-				newEmptyLine(),
-				newEmptyLine(),
-			)
+	// Working tree sections (if enabled)
+	if s.WorkTree != nil {
+		layout = append(layout, s.workTreeSections()...)
+	}
+
+	// Changes section (if restricted to changed files)
+	if s.Changes != nil && len(s.Changes.Order) > 0 {
+		base := s.Changes.Base
+		if len(base) > 12 {
+			base = base[:12]
+		}
+		layout = append(layout,
+			newHeader(fmt.Sprintf("Changes since %s (merge base %s)", s.Changes.Ref, base)),
+			newLineBlock(s.Changes.DiffLines()),
+			newEmptyLine(),
+			newSeparator(),
+			newEmptyLine(),
+		)
+	}
+
+	return layout
+}
+
+// submoduleAttrs describes a nested repository: whether it is a submodule,
+// a checkout differing from the pinned commit, and how it was collected
+func (s *Snapshot) submoduleAttrs(sub gitlog.Submodule) []string {
+	var attrs []string
+	if sub.Pinned == "" {
+		attrs = append(attrs, "nested repository")
+	}
+	if sub.Checkout != "" && sub.Checkout != sub.Pinned {
+		attrs = append(attrs, "checked out "+sub.Checkout[:7])
+	}
+	if s.recurseSubs {
+		attrs = append(attrs, "recursed")
+	} else {
+		attrs = append(attrs, "collapsed")
+	}
+	return attrs
+}
+
+// submoduleLines lists nested repositories with their pinned commit
+func (s *Snapshot) submoduleLines() []string {
+	lines := make([]string, len(s.Submodules))
+	for i, sub := range s.Submodules {
+		name := sub.Path
+		if sub.Pinned != "" {
+			name += " " + sub.Pinned[:7]
+		}
+		lines[i] = fmt.Sprintf("%s (%s)", name, strings.Join(s.submoduleAttrs(sub), ", "))
+	}
+	return lines
+}
+
+// collapsedSubmodules maps collapsed nested repositories to their tree note
+func (s *Snapshot) collapsedSubmodules() map[string]string {
+	if s.recurseSubs {
+		return nil
+	}
+	notes := make(map[string]string, len(s.Submodules))
+	for _, sub := range s.Submodules {
+		notes[sub.Path] = "nested repository"
+		if sub.Pinned != "" {
+			notes[sub.Path] = "submodule " + sub.Pinned[:7]
+		}
+	}
+	return notes
+}
+
+// collapsedEntries returns the index lines of collapsed nested repositories
+func (s *Snapshot) collapsedEntries() []string {
+	if s.recurseSubs {
+		return nil
+	}
+	lines := make([]string, len(s.Submodules))
+	for i, sub := range s.Submodules {
+		attrs := s.submoduleAttrs(sub)
+		if sub.Pinned != "" {
+			attrs = append([]string{"submodule " + sub.Pinned[:7]}, attrs...)
+		}
+		lines[i] = fmt.Sprintf("%s/ (%s)", sub.Path, strings.Join(attrs, ", "))
+	}
+	return lines
+}
+
+// workTreeSections returns the git status and diff sections
+func (s *Snapshot) workTreeSections() []Content {
+	status := s.WorkTree.StatusLines
+	if len(status) == 0 {
+		status = []string{"nothing to commit, working tree clean"}
+	}
+
+	layout := []Content{
+		newHeader("Git Status (git status --short)"),
+		newLineBlock(status),
+		newEmptyLine(),
+		newSeparator(),
+		newEmptyLine(),
+	}
+
+	diffs := []struct {
+		title string
+		lines []string
+	}{
+		{"Git Diff Staged (git diff --cached)", s.WorkTree.StagedDiff},
+		{"Git Diff Unstaged (git diff)", s.WorkTree.UnstagedDiff},
+	}
+	for _, d := range diffs {
+		if len(d.lines) == 0 {
+			continue
+		}
+		layout = append(layout,
+			newHeader(d.title),
+			newLineBlock(d.lines),
+			newEmptyLine(),
+			newSeparator(),
+			newEmptyLine(),
+		)
+	}
+
+	return layout
+}
+
+// computeDigest returns the SHA-256 of every line from the file index
+// header to the end of the snapshot, the part not covered by the summary
+func (s *Snapshot) computeDigest() (string, error) {
+	h := sha256.New()
+	lt := writer.NewLineTracker(h)
+
+	hashing := false
+	for _, content := range s.Layout {
+		if hd, ok := content.(header); ok && hd.Text == "File Index" {
+			hashing = true
+		}
+		if !hashing {
+			continue
+		}
+		if err := content.WriteTo(lt); err != nil {
+			return "", err
 		}
 	}
+	if err := lt.Flush(); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
 
-	// Assign file StartLine and calculate totalLines
+// sectionSpacing returns the blank lines between two file sections
+func sectionSpacing() []Content {
+	return []Content{newEmptyLine(), newEmptyLine()}
+}
+
+// assignLines assigns file line ranges within a layout belonging to the
+// given part (0 when not split). Returns the number of lines in the layout.
+func assignLines(layout []Content, part int) int {
 	currentLine := 1
 	for _, content := range layout {
-		if fc, ok := content.(fileContent); ok {
-			fc.File.StartLine = currentLine
+		switch c := content.(type) {
+		case fileContent:
+			c.File.StartLine = currentLine
+			c.File.EndLine = currentLine + c.LineCount() - 1
+			c.File.Part = part
+			c.File.EndPart = part
+		case fileChunk:
+			if c.From == 0 {
+				c.File.StartLine = currentLine
+				c.File.Part = part
+			}
+			if c.To == len(c.File.Lines) {
+				c.File.EndLine = currentLine + c.LineCount() - 1
+				c.File.EndPart = part
+			}
 		}
 		currentLine += content.LineCount()
 	}
 
-	totalLines = currentLine - 1 // -1 because we started at 1
-
-	snap.Layout = layout
-
-	return snap, nil
+	return currentLine - 1 // -1 because we started at 1
 }
 
-// WriteTo writes the snapshot to the output
-func (s *Snapshot) WriteTo(w io.Writer) error {
+// WriteTo writes the snapshot to the output. Split snapshots must be
+// written part by part (see WriteParts).
+func (s *Snapshot) WriteTo(w io.Writer) (int64, error) {
 	if s.Layout == nil {
-		return fmt.Errorf("layout not initialized")
+		return 0, fmt.Errorf("layout not initialized")
+	}
+	if len(s.Parts) > 0 {
+		return 0, fmt.Errorf("snapshot is split into %d parts", len(s.Parts))
 	}
 
-	lt := writer.NewLineTracker(w)
+	return writeLayout(w, s.Layout, s.compress)
+}
 
-	for _, content := range s.Layout {
+// writeLayout renders a layout to w, through gzip if compress is set.
+// Returns the number of bytes written to w.
+func writeLayout(w io.Writer, layout []Content, compress bool) (int64, error) {
+	cw := &countingWriter{w: w}
+	var out io.Writer = cw
+	var zw *gzip.Writer
+	if compress {
+		zw = gzip.NewWriter(cw)
+		out = zw
+	}
+
+	lt := writer.NewLineTracker(out)
+	for _, content := range layout {
 		if err := content.WriteTo(lt); err != nil {
-			return err
+			return cw.n, err
+		}
+	}
+	if err := lt.Flush(); err != nil {
+		return cw.n, err
+	}
+	if zw != nil {
+		if err := zw.Close(); err != nil {
+			return cw.n, err
 		}
 	}
 
-	return lt.Flush()
+	return cw.n, nil
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
 }
@@ -0,0 +1,80 @@
+package snapshot_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/neox5/snp/internal/snapshot"
+)
+
+// writeTree creates files below a temporary directory and returns it
+func writeTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// build builds a snapshot of cfg.SourceDir and renders it
+func build(t *testing.T, cfg snapshot.Config) (*snapshot.Snapshot, string) {
+	t.Helper()
+	if cfg.OutputPath == "" {
+		cfg.OutputPath = filepath.Join(cfg.SourceDir, snapshot.DefaultOutputName)
+	}
+	absSourceDir, absOutput, err := snapshot.ValidateAndResolve(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	snap, err := snapshot.Build(context.Background(), cfg, absSourceDir, absOutput)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err := snap.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return snap, buf.String()
+}
+
+// summaryField returns the value of a "Key: value" summary line
+func summaryField(output, key string) string {
+	for _, line := range strings.Split(output, "\n") {
+		if line == "" {
+			break
+		}
+		if v, ok := strings.CutPrefix(line, key+": "); ok {
+			return v
+		}
+	}
+	return ""
+}
+
+// section returns the lines of the section with this header, up to the
+// next separator
+func section(output, title string) []string {
+	var lines []string
+	in := false
+	for _, line := range strings.Split(output, "\n") {
+		switch {
+		case line == "# "+title:
+			in = true
+		case in && line == "# ----------------------------------------":
+			return lines[:len(lines)-1] // Drop the blank line before it
+		case in:
+			lines = append(lines, line)
+		}
+	}
+	return nil
+}
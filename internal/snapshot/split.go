@@ -0,0 +1,335 @@
+package snapshot
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// SplitUnit is the unit a split size is measured in
+type SplitUnit int
+
+const (
+	SplitBytes SplitUnit = iota
+	SplitLines
+	SplitTokens
+)
+
+// SplitSize is the maximum size of a single snapshot part
+type SplitSize struct {
+	Limit int64
+	Unit  SplitUnit
+}
+
+// String formats the split size the way ParseSplitSize accepts it
+func (s SplitSize) String() string {
+	switch s.Unit {
+	case SplitLines:
+		return fmt.Sprintf("%d lines", s.Limit)
+	case SplitTokens:
+		return fmt.Sprintf("%d tokens", s.Limit)
+	default:
+		return formatSize(s.Limit)
+	}
+}
+
+// ParseSplitSize parses sizes like "500KB", "2MB", "120000", "5000lines"
+// or "30000tokens". A bare number is a byte count.
+func ParseSplitSize(s string) (SplitSize, error) {
+	str := strings.ToLower(strings.TrimSpace(s))
+	if str == "" {
+		return SplitSize{}, nil
+	}
+
+	i := 0
+	for i < len(str) && str[i] >= '0' && str[i] <= '9' {
+		i++
+	}
+	n, err := strconv.ParseInt(str[:i], 10, 64)
+	if err != nil || n <= 0 {
+		return SplitSize{}, fmt.Errorf("invalid split size %q", s)
+	}
+
+	switch strings.TrimSpace(str[i:]) {
+	case "", "b", "bytes":
+		return SplitSize{Limit: n, Unit: SplitBytes}, nil
+	case "kb", "k":
+		return SplitSize{Limit: n * 1024, Unit: SplitBytes}, nil
+	case "mb", "m":
+		return SplitSize{Limit: n * 1024 * 1024, Unit: SplitBytes}, nil
+	case "lines", "line", "l":
+		return SplitSize{Limit: n, Unit: SplitLines}, nil
+	case "tokens", "token", "t":
+		return SplitSize{Limit: n, Unit: SplitTokens}, nil
+	default:
+		return SplitSize{}, fmt.Errorf("invalid split size unit in %q (expected bytes, KB, MB, lines or tokens)", s)
+	}
+}
+
+// Part is one numbered output file of a split snapshot
+type Part struct {
+	Number int
+	Total  int
+	Layout []Content
+
+	compress bool
+}
+
+// WriteTo writes the part to the output, gzip-compressed if the snapshot
+// is compressed
+func (p *Part) WriteTo(w io.Writer) (int64, error) {
+	return writeLayout(w, p.Layout, p.compress)
+}
+
+// maxSplitPasses bounds how often packing is retried when rendered parts
+// end up slightly larger than estimated (line numbers change the index)
+const maxSplitPasses = 5
+
+// split distributes the file sections over parts no larger than the split
+// size. Files are only split mid-section when they do not fit a part alone.
+func (s *Snapshot) split() error {
+	limit := s.splitSize.Limit
+
+	for pass := 0; pass < maxSplitPasses; pass++ {
+		parts, err := s.packParts(limit)
+		if err != nil {
+			return err
+		}
+
+		s.totalParts = len(parts)
+		s.totalLines = 0
+		for _, p := range parts {
+			s.totalLines += assignLines(p.Layout, p.Number)
+		}
+
+		// Verify rendered sizes; shrink the effective limit on overflow
+		overflow := int64(0)
+		for _, p := range parts {
+			if size := s.measure(p.Layout); size-s.splitSize.Limit > overflow {
+				overflow = size - s.splitSize.Limit
+			}
+		}
+		s.Parts = parts
+		if overflow == 0 {
+			return nil
+		}
+		limit -= overflow
+		if limit <= 0 {
+			break
+		}
+	}
+
+	return fmt.Errorf("split size %s is too small for this snapshot", s.splitSize)
+}
+
+// packParts greedily packs file sections into parts of at most limit
+func (s *Snapshot) packParts(limit int64) ([]*Part, error) {
+	var layouts [][]Content
+	var current []Content
+	var used int64
+	hasFiles := false
+
+	// Every part starts with a part header; the numbers are filled in later
+	headerSize := s.measure(partHeader(0, 0))
+
+	startPart := func() {
+		if current != nil {
+			layouts = append(layouts, current)
+		}
+		current = nil
+		used = headerSize
+		hasFiles = false
+	}
+
+	startPart()
+	current = append(current, s.preamble...)
+	used += s.measure(s.preamble)
+	if used > limit {
+		return nil, fmt.Errorf("split size %s is too small for the snapshot header", s.splitSize)
+	}
+
+	spacingSize := s.measure(sectionSpacing())
+
+	for _, section := range s.sections {
+		size := s.measure(section)
+		if hasFiles {
+			size += spacingSize
+		}
+
+		// Section fits the current part
+		if used+size <= limit {
+			if hasFiles {
+				current = append(current, sectionSpacing()...)
+			}
+			current = append(current, section...)
+			used += size
+			hasFiles = true
+			continue
+		}
+
+		// Section fits an empty part
+		if headerSize+s.measure(section) <= limit {
+			startPart()
+			current = append(current, section...)
+			used += s.measure(section)
+			hasFiles = true
+			continue
+		}
+
+		// Section is larger than a part: split the file across parts
+		f := section[1].(fileContent).File
+		if hasFiles {
+			startPart()
+		}
+		outputLines := f.OutputLines()
+
+		// A line larger than a part could never be placed
+		continuedSize := s.measure([]Content{newHeader(f.RelPath + " (continued)")})
+		for i, line := range outputLines {
+			if headerSize+continuedSize+s.measureLine(line) > limit {
+				return nil, fmt.Errorf("line %d of %s is larger than the split size %s", i+1, f.RelPath, s.splitSize)
+			}
+		}
+
+		from := 0
+		for {
+			title := f.RelPath
+			if from > 0 {
+				title += " (continued)"
+			}
+			hdr := newHeader(title)
+
+			// Start a new part if not even the first line fits after the
+			// preamble
+			hdrSize := s.measure([]Content{hdr})
+			if from == 0 && len(outputLines) > 0 && used+hdrSize+s.measureLine(outputLines[0]) > limit {
+				startPart()
+			}
+			used += hdrSize
+
+			to := from
+			for to < len(f.Lines) {
+				n := s.measureLine(outputLines[to])
+				if used+n > limit && to > from {
+					break
+				}
+				used += n
+				to++
+			}
+
+			current = append(current, hdr, newFileChunk(f, from, to))
+			hasFiles = true
+			if to >= len(f.Lines) {
+				break
+			}
+			startPart()
+			from = to
+		}
+	}
+
+	layouts = append(layouts, current)
+
+	parts := make([]*Part, len(layouts))
+	for i, layout := range layouts {
+		parts[i] = &Part{
+			Number: i + 1,
+			Total:  len(layouts),
+			Layout: append(partHeader(i+1, len(layouts)), layout...),
+
+			compress: s.compress,
+		}
+	}
+
+	return parts, nil
+}
+
+// partHeader returns the lines opening every part
+func partHeader(n, total int) []Content {
+	return []Content{
+		newHeader(fmt.Sprintf("Part %d of %d", n, total)),
+		newEmptyLine(),
+	}
+}
+
+// measure returns the size of content items in the split unit
+func (s *Snapshot) measure(layout []Content) int64 {
+	var size int64
+	for _, c := range layout {
+		switch s.splitSize.Unit {
+		case SplitLines:
+			size += int64(c.LineCount())
+		case SplitTokens:
+			if fc, ok := c.(fileContent); ok {
+				size += int64(fc.File.Tokens)
+			} else {
+				size += int64(s.tokenizer.Count(render(c)))
+			}
+		default:
+			size += int64(len(render(c)))
+		}
+	}
+	return size
+}
+
+// measureLine returns the size of a single content line in the split unit
+func (s *Snapshot) measureLine(line string) int64 {
+	switch s.splitSize.Unit {
+	case SplitLines:
+		return 1
+	case SplitTokens:
+		return int64(s.tokenizer.Count(line)) + 1
+	default:
+		return int64(len(line)) + 1
+	}
+}
+
+// PartPath returns the output path of part n, e.g. snapshot.002.snp or
+// snapshot.002.snp.gz
+func PartPath(absOutput string, n, total int) string {
+	base, gz := strings.CutSuffix(absOutput, gzipExt)
+	ext := filepath.Ext(base)
+	base = strings.TrimSuffix(base, ext)
+	if gz {
+		ext += gzipExt
+	}
+
+	width := len(strconv.Itoa(total))
+	if width < 3 {
+		width = 3
+	}
+
+	return fmt.Sprintf("%s.%0*d%s", base, width, n, ext)
+}
+
+// WriteParts writes every part next to absOutput and removes stale parts
+// left over from an earlier run with more parts. Returns the written paths.
+func (s *Snapshot) WriteParts(absOutput string) ([]string, error) {
+	if len(s.Parts) == 0 {
+		return nil, fmt.Errorf("snapshot is not split")
+	}
+
+	var paths []string
+	for _, p := range s.Parts {
+		path := PartPath(absOutput, p.Number, p.Total)
+		if err := WriteFile(path, p); err != nil {
+			return paths, err
+		}
+		paths = append(paths, path)
+	}
+
+	for n := len(s.Parts) + 1; ; n++ {
+		err := os.Remove(PartPath(absOutput, n, len(s.Parts)))
+		if errors.Is(err, os.ErrNotExist) {
+			break
+		}
+		if err != nil {
+			return paths, fmt.Errorf("cannot remove stale part: %w", err)
+		}
+	}
+
+	return paths, nil
+}
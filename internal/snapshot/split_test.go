@@ -0,0 +1,169 @@
+package snapshot_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/neox5/snp/internal/snapshot"
+)
+
+func TestParseSplitSize(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    snapshot.SplitSize
+		wantErr bool
+	}{
+		{"", snapshot.SplitSize{}, false},
+		{"120000", snapshot.SplitSize{Limit: 120000, Unit: snapshot.SplitBytes}, false},
+		{"500KB", snapshot.SplitSize{Limit: 500 * 1024, Unit: snapshot.SplitBytes}, false},
+		{"2 mb", snapshot.SplitSize{Limit: 2 * 1024 * 1024, Unit: snapshot.SplitBytes}, false},
+		{"5000lines", snapshot.SplitSize{Limit: 5000, Unit: snapshot.SplitLines}, false},
+		{"30000Tokens", snapshot.SplitSize{Limit: 30000, Unit: snapshot.SplitTokens}, false},
+		{"0", snapshot.SplitSize{}, true},
+		{"KB", snapshot.SplitSize{}, true},
+		{"10 parsecs", snapshot.SplitSize{}, true},
+	}
+
+	for _, tt := range tests {
+		got, err := snapshot.ParseSplitSize(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseSplitSize(%q) = %v, %v; want %v, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestPartPath(t *testing.T) {
+	tests := []struct {
+		output   string
+		n, total int
+		want     string
+	}{
+		{"/out/snapshot.snp", 2, 5, "/out/snapshot.002.snp"},
+		{"/out/snapshot.snp.gz", 1, 3, "/out/snapshot.001.snp.gz"},
+		{"/out/code.txt", 12, 1200, "/out/code.0012.txt"},
+		{"/out/noext", 1, 2, "/out/noext.001"},
+	}
+	for _, tt := range tests {
+		if got := snapshot.PartPath(tt.output, tt.n, tt.total); got != tt.want {
+			t.Errorf("PartPath(%q, %d, %d) = %q, want %q", tt.output, tt.n, tt.total, got, tt.want)
+		}
+	}
+}
+
+// splitSnapshot builds dir split by size and renders every part
+func splitSnapshot(t *testing.T, dir, size string) (*snapshot.Snapshot, []string, error) {
+	t.Helper()
+	splitSize, err := snapshot.ParseSplitSize(size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := snapshot.Config{SourceDir: dir, OutputPath: filepath.Join(dir, "out.snp"), SplitSize: splitSize}
+	snap, err := snapshot.Build(context.Background(), cfg, dir, cfg.OutputPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	var parts []string
+	for _, p := range snap.Parts {
+		var buf bytes.Buffer
+		if _, err := p.WriteTo(&buf); err != nil {
+			t.Fatal(err)
+		}
+		parts = append(parts, buf.String())
+	}
+	return snap, parts, nil
+}
+
+func TestSplit_PartsFitLimit(t *testing.T) {
+	dir := writeTree(t, sourceFiles(6, 1500))
+	snap, parts, err := splitSnapshot(t, dir, "2KB")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parts) < 2 {
+		t.Fatalf("got %d parts, want several", len(parts))
+	}
+
+	var content strings.Builder
+	for i, p := range parts {
+		if len(p) > 2048 {
+			t.Errorf("part %d has %d bytes, limit 2048", i+1, len(p))
+		}
+		if !strings.HasPrefix(p, "# Part ") {
+			t.Errorf("part %d lacks a part header", i+1)
+		}
+		content.WriteString(p)
+	}
+
+	// Every line of every file is written exactly once
+	for _, f := range snap.Files {
+		for _, line := range f.Lines {
+			if n := strings.Count(content.String(), "\n"+line+"\n"); n != 1 {
+				t.Fatalf("%s: line %q written %d times", f.RelPath, line, n)
+			}
+		}
+	}
+}
+
+func TestSplit_LineLargerThanPart(t *testing.T) {
+	dir := writeTree(t, map[string]string{
+		"small.txt": "ok\n",
+		"logo.svg":  "<svg>\n" + strings.Repeat("x", 5000) + "\n</svg>\n",
+	})
+	_, _, err := splitSnapshot(t, dir, "2KB")
+	if err == nil || !strings.Contains(err.Error(), "line 2 of logo.svg") {
+		t.Errorf("err = %v, want an error naming line 2 of logo.svg", err)
+	}
+}
+
+func TestWriteParts_RemovesStaleParts(t *testing.T) {
+	dir := writeTree(t, sourceFiles(6, 1500))
+	output := filepath.Join(t.TempDir(), "out.snp")
+
+	// Stale parts of an earlier run with more parts
+	for _, name := range []string{"out.008.snp", "out.009.snp", "out.010.snp", "other.001.snp"} {
+		if err := os.WriteFile(filepath.Join(filepath.Dir(output), name), []byte("old"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	splitSize, _ := snapshot.ParseSplitSize("4KB")
+	cfg := snapshot.Config{SourceDir: dir, OutputPath: output, SplitSize: splitSize}
+	snap, err := snapshot.Build(context.Background(), cfg, dir, output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snap.Parts) >= 8 {
+		t.Fatalf("got %d parts, test needs fewer than 8", len(snap.Parts))
+	}
+
+	// Pretend the parts in between were written by the earlier run too
+	for n := len(snap.Parts) + 1; n < 8; n++ {
+		if err := os.WriteFile(snapshot.PartPath(output, n, len(snap.Parts)), []byte("old"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	paths, err := snap.WriteParts(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != len(snap.Parts) {
+		t.Errorf("wrote %d paths, want %d", len(paths), len(snap.Parts))
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(output))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if len(names) != len(snap.Parts)+1 || !strings.Contains(strings.Join(names, " "), "other.001.snp") {
+		t.Errorf("directory holds %v, want %d parts and other.001.snp", names, len(snap.Parts))
+	}
+}
@@ -0,0 +1,115 @@
+package snapshot
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/neox5/snp/internal/file"
+)
+
+// treeNode is a directory or file in the directory tree
+type treeNode struct {
+	Name     string
+	File     *file.File // nil for directories
+	Note     string     // set for collapsed directories, e.g. "excluded"
+	Children []*treeNode
+
+	fileCount int
+	size      int64
+}
+
+// child returns the named child directory, creating it if needed
+func (n *treeNode) child(name string) *treeNode {
+	for _, c := range n.Children {
+		if c.Name == name && c.File == nil {
+			return c
+		}
+	}
+	c := &treeNode{Name: name}
+	n.Children = append(n.Children, c)
+	return c
+}
+
+// buildTree builds the directory tree of files, excluded directories and
+// collapsed directories with their notes
+func buildTree(files []*file.File, excludedDirs []string, collapsed map[string]string) *treeNode {
+	root := &treeNode{Name: "."}
+
+	for _, f := range files {
+		parts := strings.Split(f.RelPath, "/")
+		node := root
+		node.fileCount++
+		node.size += f.Size
+		for _, dir := range parts[:len(parts)-1] {
+			node = node.child(dir)
+			node.fileCount++
+			node.size += f.Size
+		}
+		node.Children = append(node.Children, &treeNode{Name: parts[len(parts)-1], File: f})
+	}
+
+	notes := make(map[string]string, len(excludedDirs)+len(collapsed))
+	for _, dir := range excludedDirs {
+		notes[dir] = "excluded"
+	}
+	for dir, note := range collapsed {
+		notes[dir] = note
+	}
+	for dir, note := range notes {
+		parts := strings.Split(dir, "/")
+		node := root
+		for _, p := range parts[:len(parts)-1] {
+			node = node.child(p)
+		}
+		node.Children = append(node.Children, &treeNode{Name: parts[len(parts)-1], Note: note})
+	}
+
+	root.sort()
+	return root
+}
+
+// sort orders children by name, recursively
+func (n *treeNode) sort() {
+	sort.SliceStable(n.Children, func(i, j int) bool {
+		return n.Children[i].Name < n.Children[j].Name
+	})
+	for _, c := range n.Children {
+		c.sort()
+	}
+}
+
+// label renders the node's own line without tree prefix
+func (n *treeNode) label() string {
+	switch {
+	case n.File != nil:
+		return fmt.Sprintf("%s (%s)", n.Name, formatSize(n.File.Size))
+	case n.Note != "":
+		return fmt.Sprintf("%s/ (%s)", n.Name, n.Note)
+	default:
+		return fmt.Sprintf("%s/ (%s, %s)", n.Name, formatCount(n.fileCount, "file", "files"), formatSize(n.size))
+	}
+}
+
+// lines renders the tree like the tree(1) command
+func (n *treeNode) lines() []string {
+	lines := []string{n.label()}
+	n.appendChildren(&lines, "")
+	return lines
+}
+
+func (n *treeNode) appendChildren(lines *[]string, prefix string) {
+	for i, c := range n.Children {
+		branch, indent := "├── ", "│   "
+		if i == len(n.Children)-1 {
+			branch, indent = "└── ", "    "
+		}
+		*lines = append(*lines, prefix+branch+c.label())
+		c.appendChildren(lines, prefix+indent)
+	}
+}
+
+// newTree creates the directory tree section content
+func newTree(files []*file.File, excludedDirs []string, collapsed map[string]string) Content {
+	return newLineBlock(buildTree(files, excludedDirs, collapsed).lines())
+}
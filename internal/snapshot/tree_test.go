@@ -0,0 +1,87 @@
+package snapshot_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/neox5/snp/internal/snapshot"
+)
+
+func TestTree(t *testing.T) {
+	dir := writeTree(t, map[string]string{
+		".gitignore":     "*.log\n",
+		"README.md":      "r\n",
+		"app.log":        "ignored\n",
+		"build/out.txt":  "b\n",
+		"docs/Z.md":      "z\n",
+		"docs/a.md":      "a\n",
+		"src/main.go":    "hello\n",
+		"src/util/x.go":  "hi\n",
+		"src/util/y.txt": "",
+	})
+	if err := os.Mkdir(filepath.Join(dir, "empty"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		cfg  snapshot.Config
+		want []string
+	}{
+		{
+			// Names sort bytewise with files and directories mixed; empty
+			// directories are left out
+			name: "nested",
+			cfg:  snapshot.Config{IncludeTree: true, ExcludePatterns: []string{"build/"}},
+			want: []string{
+				"./ (7 files, 21 bytes)",
+				"├── .gitignore (6 bytes)",
+				"├── README.md (2 bytes)",
+				"├── docs/ (2 files, 4 bytes)",
+				"│   ├── Z.md (2 bytes)",
+				"│   └── a.md (2 bytes)",
+				"└── src/ (3 files, 9 bytes)",
+				"    ├── main.go (6 bytes)",
+				"    └── util/ (2 files, 3 bytes)",
+				"        ├── x.go (3 bytes)",
+				"        └── y.txt (0 bytes)",
+			},
+		},
+		{
+			name: "excluded directories collapsed",
+			cfg:  snapshot.Config{TreeExcluded: true, ExcludePatterns: []string{"build/", "src/util/"}},
+			want: []string{
+				"./ (5 files, 18 bytes)",
+				"├── .gitignore (6 bytes)",
+				"├── README.md (2 bytes)",
+				"├── build/ (excluded)",
+				"├── docs/ (2 files, 4 bytes)",
+				"│   ├── Z.md (2 bytes)",
+				"│   └── a.md (2 bytes)",
+				"└── src/ (1 file, 6 bytes)",
+				"    ├── main.go (6 bytes)",
+				"    └── util/ (excluded)",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.SourceDir = dir
+			_, output := build(t, tt.cfg)
+			if got := section(output, "Directory Tree"); !slices.Equal(got, tt.want) {
+				t.Errorf("tree:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestTree_Disabled(t *testing.T) {
+	dir := writeTree(t, map[string]string{"a.txt": "a\n"})
+	if _, output := build(t, snapshot.Config{SourceDir: dir}); section(output, "Directory Tree") != nil {
+		t.Error("tree written without IncludeTree")
+	}
+}
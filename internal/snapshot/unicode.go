@@ -0,0 +1,50 @@
+package snapshot
+
+import (
+	"github.com/neox5/snp/internal/unicheck"
+)
+
+// UnicodeSummary describes the files containing suspicious characters,
+// e.g. "2 files (3 bidi, 1 invisible)", or "" if there are none
+func (s *Snapshot) UnicodeSummary() string {
+	var findings []unicheck.Finding
+	files := 0
+	for _, f := range s.Files {
+		if len(f.Unicode) > 0 {
+			files++
+			findings = append(findings, f.Unicode...)
+		}
+	}
+	if files == 0 {
+		return ""
+	}
+	return formatCount(files, "file", "files") + " (" + unicheck.Describe(findings) + ")"
+}
+
+// escapeUnicode renders suspicious characters in text files, the git log
+// and diff sections as visible escapes
+func (s *Snapshot) escapeUnicode() {
+	for _, f := range s.Files {
+		if len(f.Unicode) == 0 {
+			continue
+		}
+		byLine := make(map[int][]unicheck.Finding)
+		for _, u := range f.Unicode {
+			byLine[u.Line] = append(byLine[u.Line], u)
+		}
+		for line, found := range byLine {
+			f.Lines[line-1] = unicheck.Escape(f.Lines[line-1], found)
+		}
+	}
+
+	unicheck.EscapeLines(s.GitLogLines)
+	if s.WorkTree != nil {
+		unicheck.EscapeLines(s.WorkTree.StagedDiff)
+		unicheck.EscapeLines(s.WorkTree.UnstagedDiff)
+	}
+	if s.Changes != nil {
+		for _, p := range s.Changes.Order {
+			unicheck.EscapeLines(s.Changes.Diffs[p])
+		}
+	}
+}
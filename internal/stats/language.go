@@ -0,0 +1,162 @@
+package stats
+
+import (
+	"path"
+	"strings"
+)
+
+// Fallback language names
+const (
+	Text   = "Text"
+	Binary = "Binary"
+)
+
+// syntax describes how a language marks comments
+type syntax struct {
+	Line       []string // Line comment prefixes
+	BlockStart string
+	BlockEnd   string
+}
+
+var (
+	cStyle    = syntax{Line: []string{"//"}, BlockStart: "/*", BlockEnd: "*/"}
+	hashStyle = syntax{Line: []string{"#"}}
+	dashStyle = syntax{Line: []string{"--"}}
+	markup    = syntax{BlockStart: "<!--", BlockEnd: "-->"}
+	noComment = syntax{}
+)
+
+// language pairs a display name with its comment syntax
+type language struct {
+	Name   string
+	Syntax syntax
+}
+
+// extensions maps lower-case file extensions to languages
+var extensions = map[string]language{
+	".go":    {"Go", cStyle},
+	".c":     {"C", cStyle},
+	".h":     {"C", cStyle},
+	".cc":    {"C++", cStyle},
+	".cpp":   {"C++", cStyle},
+	".cxx":   {"C++", cStyle},
+	".hpp":   {"C++", cStyle},
+	".cs":    {"C#", cStyle},
+	".java":  {"Java", cStyle},
+	".kt":    {"Kotlin", cStyle},
+	".kts":   {"Kotlin", cStyle},
+	".scala": {"Scala", cStyle},
+	".swift": {"Swift", cStyle},
+	".rs":    {"Rust", cStyle},
+	".js":    {"JavaScript", cStyle},
+	".mjs":   {"JavaScript", cStyle},
+	".cjs":   {"JavaScript", cStyle},
+	".jsx":   {"JavaScript", cStyle},
+	".ts":    {"TypeScript", cStyle},
+	".tsx":   {"TypeScript", cStyle},
+	".css":   {"CSS", syntax{BlockStart: "/*", BlockEnd: "*/"}},
+	".scss":  {"SCSS", cStyle},
+	".proto": {"Protocol Buffers", cStyle},
+	".py":    {"Python", syntax{Line: []string{"#"}, BlockStart: `"""`, BlockEnd: `"""`}},
+	".rb":    {"Ruby", hashStyle},
+	".pl":    {"Perl", hashStyle},
+	".sh":    {"Shell", hashStyle},
+	".bash":  {"Shell", hashStyle},
+	".zsh":   {"Shell", hashStyle},
+	".ps1":   {"PowerShell", syntax{Line: []string{"#"}, BlockStart: "<#", BlockEnd: "#>"}},
+	".yaml":  {"YAML", hashStyle},
+	".yml":   {"YAML", hashStyle},
+	".toml":  {"TOML", hashStyle},
+	".ini":   {"INI", syntax{Line: []string{";", "#"}}},
+	".cfg":   {"INI", syntax{Line: []string{";", "#"}}},
+	".r":     {"R", hashStyle},
+	".sql":   {"SQL", syntax{Line: []string{"--"}, BlockStart: "/*", BlockEnd: "*/"}},
+	".lua":   {"Lua", syntax{Line: []string{"--"}, BlockStart: "--[[", BlockEnd: "]]"}},
+	".hs":    {"Haskell", syntax{Line: []string{"--"}, BlockStart: "{-", BlockEnd: "-}"}},
+	".elm":   {"Elm", dashStyle},
+	".php":   {"PHP", syntax{Line: []string{"//", "#"}, BlockStart: "/*", BlockEnd: "*/"}},
+	".html":  {"HTML", markup},
+	".htm":   {"HTML", markup},
+	".xml":   {"XML", markup},
+	".svg":   {"SVG", markup},
+	".vue":   {"Vue", markup},
+	".md":    {"Markdown", markup},
+	".json":  {"JSON", noComment},
+	".txt":   {"Text", noComment},
+	".csv":   {"CSV", noComment},
+	".mod":   {"Go Module", cStyle},
+	".sum":   {"Go Checksums", noComment},
+	".tf":    {"Terraform", syntax{Line: []string{"#", "//"}, BlockStart: "/*", BlockEnd: "*/"}},
+	".vim":   {"Vim Script", syntax{Line: []string{`"`}}},
+	".el":    {"Emacs Lisp", syntax{Line: []string{";"}}},
+	".clj":   {"Clojure", syntax{Line: []string{";"}}},
+	".ex":    {"Elixir", hashStyle},
+	".exs":   {"Elixir", hashStyle},
+	".erl":   {"Erlang", syntax{Line: []string{"%"}}},
+	".tex":   {"TeX", syntax{Line: []string{"%"}}},
+	".dart":  {"Dart", cStyle},
+	".zig":   {"Zig", syntax{Line: []string{"//"}}},
+}
+
+// filenames maps lower-case base names without a telling extension
+var filenames = map[string]language{
+	"makefile":       {"Makefile", hashStyle},
+	"gnumakefile":    {"Makefile", hashStyle},
+	"dockerfile":     {"Dockerfile", hashStyle},
+	"containerfile":  {"Dockerfile", hashStyle},
+	"cmakelists.txt": {"CMake", hashStyle},
+	".gitignore":     {"Ignore List", hashStyle},
+	".dockerignore":  {"Ignore List", hashStyle},
+	".gitattributes": {"Ignore List", hashStyle},
+	".editorconfig":  {"INI", syntax{Line: []string{";", "#"}}},
+	"license":        {"Text", noComment},
+}
+
+// interpreters maps shebang interpreters to languages
+var interpreters = map[string]language{
+	"sh":      {"Shell", hashStyle},
+	"bash":    {"Shell", hashStyle},
+	"zsh":     {"Shell", hashStyle},
+	"python":  {"Python", extensions[".py"].Syntax},
+	"python3": {"Python", extensions[".py"].Syntax},
+	"ruby":    {"Ruby", hashStyle},
+	"perl":    {"Perl", hashStyle},
+	"node":    {"JavaScript", cStyle},
+}
+
+// detect returns the language of a text file from its name and first line
+func detect(relPath, firstLine string) language {
+	base := strings.ToLower(path.Base(relPath))
+	if lang, ok := filenames[base]; ok {
+		return lang
+	}
+	if lang, ok := extensions[path.Ext(base)]; ok {
+		return lang
+	}
+	if lang, ok := interpreters[shebangInterpreter(firstLine)]; ok {
+		return lang
+	}
+	return language{Text, noComment}
+}
+
+// shebangInterpreter extracts the interpreter name from a "#!" line,
+// following "/usr/bin/env" indirection
+func shebangInterpreter(line string) string {
+	if !strings.HasPrefix(line, "#!") {
+		return ""
+	}
+	fields := strings.Fields(line[2:])
+	if len(fields) == 0 {
+		return ""
+	}
+	name := path.Base(fields[0])
+	if name == "env" {
+		for _, f := range fields[1:] {
+			if !strings.HasPrefix(f, "-") {
+				return f
+			}
+		}
+		return ""
+	}
+	return name
+}
@@ -0,0 +1,175 @@
+// Package stats computes language and size statistics over collected
+// files: per-language file, line and byte counts, code/comment/blank line
+// classification, and the largest files.
+package stats
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/neox5/snp/internal/file"
+)
+
+// DefaultTopN is the default number of largest files reported
+const DefaultTopN = 5
+
+// Language holds the totals for one language
+type Language struct {
+	Name    string
+	Files   int
+	Lines   int
+	Code    int
+	Comment int
+	Blank   int
+	Bytes   int64
+}
+
+// Stats holds statistics over a set of files
+type Stats struct {
+	Languages []*Language  // Sorted by lines, then bytes, descending
+	Largest   []*file.File // Largest files by size, descending
+	Total     Language     // Totals over all languages
+}
+
+// Compute gathers statistics over files, reporting the topN largest files
+func Compute(files []*file.File, topN int) *Stats {
+	byName := make(map[string]*Language)
+	st := &Stats{Total: Language{Name: "Total"}}
+
+	for _, f := range files {
+		var lang language
+		if f.IsBinary {
+			lang = language{Name: Binary}
+		} else {
+			first := ""
+			if len(f.Lines) > 0 {
+				first = f.Lines[0]
+			}
+			lang = detect(f.RelPath, first)
+		}
+
+		l, ok := byName[lang.Name]
+		if !ok {
+			l = &Language{Name: lang.Name}
+			byName[lang.Name] = l
+			st.Languages = append(st.Languages, l)
+		}
+
+		l.Files++
+		l.Bytes += f.Size
+		if !f.IsBinary {
+			code, comment, blank := classify(f.Lines, lang.Syntax)
+			l.Lines += len(f.Lines)
+			l.Code += code
+			l.Comment += comment
+			l.Blank += blank
+		}
+	}
+
+	for _, l := range st.Languages {
+		st.Total.Files += l.Files
+		st.Total.Lines += l.Lines
+		st.Total.Code += l.Code
+		st.Total.Comment += l.Comment
+		st.Total.Blank += l.Blank
+		st.Total.Bytes += l.Bytes
+	}
+
+	sort.SliceStable(st.Languages, func(i, j int) bool {
+		a, b := st.Languages[i], st.Languages[j]
+		if a.Lines != b.Lines {
+			return a.Lines > b.Lines
+		}
+		return a.Bytes > b.Bytes
+	})
+
+	st.Largest = append([]*file.File(nil), files...)
+	sort.SliceStable(st.Largest, func(i, j int) bool {
+		return st.Largest[i].Size > st.Largest[j].Size
+	})
+	if len(st.Largest) > topN {
+		st.Largest = st.Largest[:topN]
+	}
+
+	return st
+}
+
+// classify counts code, comment and blank lines. Lines mixing code and a
+// trailing comment count as code.
+func classify(lines []string, syn syntax) (code, comment, blank int) {
+	inBlock := false
+
+	for _, raw := range lines {
+		line := strings.TrimSpace(raw)
+
+		switch {
+		case line == "":
+			blank++
+
+		case inBlock:
+			comment++
+			if strings.Contains(line, syn.BlockEnd) {
+				inBlock = false
+			}
+
+		case hasLinePrefix(line, syn.Line):
+			comment++
+
+		case syn.BlockStart != "" && strings.HasPrefix(line, syn.BlockStart):
+			comment++
+			rest := line[len(syn.BlockStart):]
+			inBlock = !strings.Contains(rest, syn.BlockEnd)
+
+		default:
+			code++
+			if syn.BlockStart != "" {
+				if i := strings.LastIndex(line, syn.BlockStart); i >= 0 {
+					inBlock = !strings.Contains(line[i+len(syn.BlockStart):], syn.BlockEnd)
+				}
+			}
+		}
+	}
+
+	return code, comment, blank
+}
+
+func hasLinePrefix(line string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(line, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// Lines renders the statistics as aligned text lines
+func (st *Stats) Lines() []string {
+	var lines []string
+
+	lines = append(lines, fmt.Sprintf("%-20s %6s %8s %8s %8s %8s %10s %7s",
+		"Language", "Files", "Lines", "Code", "Comment", "Blank", "Size", "Share"))
+	rows := append([]*Language(nil), st.Languages...)
+	rows = append(rows, &st.Total)
+	for _, l := range rows {
+		lines = append(lines, fmt.Sprintf("%-20s %6d %8d %8d %8d %8d %10s %6.1f%%",
+			l.Name, l.Files, l.Lines, l.Code, l.Comment, l.Blank,
+			file.FormatSize(l.Bytes), percent(l.Lines, st.Total.Lines)))
+	}
+
+	if len(st.Largest) > 0 {
+		lines = append(lines, "", "Largest files:")
+		for _, f := range st.Largest {
+			lines = append(lines, fmt.Sprintf("  %s (%s)", f.RelPath, file.FormatSize(f.Size)))
+		}
+	}
+
+	return lines
+}
+
+func percent(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) * 100 / float64(total)
+}
@@ -0,0 +1,57 @@
+package stats_test
+
+import (
+	"testing"
+
+	"github.com/neox5/snp/internal/file"
+	"github.com/neox5/snp/internal/stats"
+)
+
+func TestCompute_Languages(t *testing.T) {
+	tests := []struct {
+		path      string
+		firstLine string
+		want      string
+	}{
+		{path: "cmd/snp/main.go", want: "Go"},
+		{path: "Makefile", want: "Makefile"},
+		{path: "scripts/build", firstLine: "#!/usr/bin/env bash", want: "Shell"},
+		{path: "tool", firstLine: "#!/usr/bin/python3", want: "Python"},
+		{path: "notes", want: stats.Text},
+	}
+
+	for _, tt := range tests {
+		f := &file.File{RelPath: tt.path, Lines: []string{tt.firstLine}}
+		st := stats.Compute([]*file.File{f}, stats.DefaultTopN)
+		if got := st.Languages[0].Name; got != tt.want {
+			t.Errorf("language of %q with first line %q = %q, want %q", tt.path, tt.firstLine, got, tt.want)
+		}
+	}
+}
+
+func TestCompute_LineClassification(t *testing.T) {
+	f := &file.File{
+		RelPath: "main.go",
+		Size:    100,
+		Lines: []string{
+			"// Package main does things",
+			"package main",
+			"",
+			"/*",
+			"block comment",
+			"*/",
+			"func main() {} // trailing comment counts as code",
+		},
+	}
+
+	st := stats.Compute([]*file.File{f}, stats.DefaultTopN)
+	if len(st.Languages) != 1 {
+		t.Fatalf("got %d languages, want 1", len(st.Languages))
+	}
+
+	got := st.Languages[0]
+	if got.Code != 2 || got.Comment != 4 || got.Blank != 1 {
+		t.Errorf("got code=%d comment=%d blank=%d, want code=2 comment=4 blank=1",
+			got.Code, got.Comment, got.Blank)
+	}
+}
@@ -0,0 +1,231 @@
+// Package textdiff computes line diffs and renders them as unified diffs.
+package textdiff
+
+import "fmt"
+
+// Op is the kind of an edit
+type Op int
+
+const (
+	Equal Op = iota
+	Delete
+	Insert
+)
+
+// Edit is one line of a diff
+type Edit struct {
+	Op   Op
+	Line string
+}
+
+// maxEditDistance bounds the Myers search; beyond it the differing middle
+// is reported as one deletion followed by one insertion
+const maxEditDistance = 4096
+
+// Lines returns a shortest edit script turning a into b (Myers' algorithm),
+// after stripping the common prefix and suffix
+func Lines(a, b []string) []Edit {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var edits []Edit
+	for _, line := range a[:prefix] {
+		edits = append(edits, Edit{Equal, line})
+	}
+	edits = append(edits, myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, line := range a[len(a)-suffix:] {
+		edits = append(edits, Edit{Equal, line})
+	}
+	return edits
+}
+
+// myers runs the greedy forward algorithm, keeping the frontier of each
+// round to backtrack the path
+func myers(a, b []string) []Edit {
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 {
+		return replace(a, b)
+	}
+
+	maxD := min(n+m, maxEditDistance)
+	offset := maxD + 1
+	v := make([]int, 2*maxD+3)
+	var trace [][]int
+
+	for d := 0; d <= maxD; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d-1:offset+d+2]...))
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1] // down: insertion
+			} else {
+				x = v[offset+k-1] + 1 // right: deletion
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+
+			if x >= n && y >= m {
+				return backtrack(a, b, trace, d)
+			}
+		}
+	}
+
+	return replace(a, b)
+}
+
+// backtrack walks the saved frontiers from (len(a), len(b)) to the origin
+func backtrack(a, b []string, trace [][]int, d int) []Edit {
+	x, y := len(a), len(b)
+	var rev []Edit
+
+	for ; d > 0; d-- {
+		// trace[d] holds v[-d-1 .. d+1] as it was before round d
+		prev := trace[d]
+		at := func(k int) int { return prev[k+d+1] }
+
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			rev = append(rev, Edit{Equal, a[x]})
+		}
+		if x == prevX {
+			y--
+			rev = append(rev, Edit{Insert, b[y]})
+		} else {
+			x--
+			rev = append(rev, Edit{Delete, a[x]})
+		}
+	}
+	for x > 0 && y > 0 {
+		x--
+		y--
+		rev = append(rev, Edit{Equal, a[x]})
+	}
+
+	edits := make([]Edit, len(rev))
+	for i, e := range rev {
+		edits[len(rev)-1-i] = e
+	}
+	return edits
+}
+
+// replace deletes all of a and inserts all of b
+func replace(a, b []string) []Edit {
+	edits := make([]Edit, 0, len(a)+len(b))
+	for _, line := range a {
+		edits = append(edits, Edit{Delete, line})
+	}
+	for _, line := range b {
+		edits = append(edits, Edit{Insert, line})
+	}
+	return edits
+}
+
+// Count returns the number of inserted and deleted lines
+func Count(edits []Edit) (added, removed int) {
+	for _, e := range edits {
+		switch e.Op {
+		case Insert:
+			added++
+		case Delete:
+			removed++
+		}
+	}
+	return added, removed
+}
+
+// Unified renders edits as a unified diff with the given context lines.
+// It returns nil when there are no changes.
+func Unified(oldName, newName string, edits []Edit, context int) []string {
+	// Line numbers of each edit in a and b (1-based, before the edit)
+	type pos struct{ a, b int }
+	positions := make([]pos, len(edits))
+	ai, bi := 1, 1
+	var changed []int
+	for i, e := range edits {
+		positions[i] = pos{ai, bi}
+		switch e.Op {
+		case Equal:
+			ai++
+			bi++
+		case Delete:
+			ai++
+			changed = append(changed, i)
+		case Insert:
+			bi++
+			changed = append(changed, i)
+		}
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+
+	lines := []string{"--- " + oldName, "+++ " + newName}
+
+	for i := 0; i < len(changed); {
+		// Grow the hunk while the gap to the next change fits two contexts
+		first, last := changed[i], changed[i]
+		for i++; i < len(changed) && changed[i]-last <= 2*context+1; i++ {
+			last = changed[i]
+		}
+		start := max(first-context, 0)
+		end := min(last+context+1, len(edits))
+
+		var aLen, bLen int
+		var body []string
+		for _, e := range edits[start:end] {
+			switch e.Op {
+			case Equal:
+				aLen++
+				bLen++
+				body = append(body, " "+e.Line)
+			case Delete:
+				aLen++
+				body = append(body, "-"+e.Line)
+			case Insert:
+				bLen++
+				body = append(body, "+"+e.Line)
+			}
+		}
+
+		lines = append(lines, fmt.Sprintf("@@ -%s +%s @@",
+			hunkRange(positions[start].a, aLen), hunkRange(positions[start].b, bLen)))
+		lines = append(lines, body...)
+	}
+
+	return lines
+}
+
+// hunkRange formats a hunk range like diff -u: "start,len", with "start"
+// alone for one line and the line before for empty ranges
+func hunkRange(start, length int) string {
+	switch length {
+	case 0:
+		return fmt.Sprintf("%d,0", start-1)
+	case 1:
+		return fmt.Sprintf("%d", start)
+	default:
+		return fmt.Sprintf("%d,%d", start, length)
+	}
+}
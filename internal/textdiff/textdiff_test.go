@@ -0,0 +1,89 @@
+package textdiff_test
+
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/neox5/snp/internal/textdiff"
+)
+
+// lcs returns the length of the longest common subsequence
+func lcs(a, b []string) int {
+	dp := make([][]int, len(a)+1)
+	for i := range dp {
+		dp[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				dp[i][j] = dp[i+1][j+1] + 1
+			} else {
+				dp[i][j] = max(dp[i+1][j], dp[i][j+1])
+			}
+		}
+	}
+	return dp[0][0]
+}
+
+func TestLines_MinimalAndConsistent(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	words := []string{"a", "b", "c", "d"}
+	gen := func() []string {
+		lines := make([]string, rng.Intn(12))
+		for i := range lines {
+			lines[i] = words[rng.Intn(len(words))]
+		}
+		return lines
+	}
+
+	for i := 0; i < 500; i++ {
+		a, b := gen(), gen()
+		edits := textdiff.Lines(a, b)
+
+		var gotA, gotB []string
+		for _, e := range edits {
+			if e.Op != textdiff.Insert {
+				gotA = append(gotA, e.Line)
+			}
+			if e.Op != textdiff.Delete {
+				gotB = append(gotB, e.Line)
+			}
+		}
+		if strings.Join(gotA, ",") != strings.Join(a, ",") || strings.Join(gotB, ",") != strings.Join(b, ",") {
+			t.Fatalf("edits for %v -> %v do not reproduce inputs", a, b)
+		}
+
+		added, removed := textdiff.Count(edits)
+		if want := len(a) + len(b) - 2*lcs(a, b); added+removed != want {
+			t.Fatalf("%v -> %v: %d edits, want %d", a, b, added+removed, want)
+		}
+	}
+}
+
+func TestUnified(t *testing.T) {
+	a := []string{"1", "2", "3", "4", "5", "6", "7", "8", "9", "10"}
+	b := []string{"1", "2", "three", "4", "5", "6", "7", "8", "9", "10", "11"}
+
+	got := textdiff.Unified("a/f", "b/f", textdiff.Lines(a, b), 1)
+	want := []string{
+		"--- a/f",
+		"+++ b/f",
+		"@@ -2,3 +2,3 @@",
+		" 2",
+		"-3",
+		"+three",
+		" 4",
+		"@@ -10 +10,2 @@",
+		" 10",
+		"+11",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Unified =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	if got := textdiff.Unified("a", "b", textdiff.Lines(a, a), 3); got != nil {
+		t.Errorf("Unified of equal input = %v, want nil", got)
+	}
+}
@@ -0,0 +1,132 @@
+package token
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// merges.txt holds the ranked merge table, one pair of Go-quoted byte
+// strings per line, most frequent merge first. It was trained on a mix of
+// Go source code and English documentation.
+//
+//go:embed merges.txt
+var mergesData string
+
+// pretokenize splits text into words before merging, GPT-2 style
+var pretokenize = regexp.MustCompile(`'s|'t|'re|'ve|'m|'ll|'d| ?\pL+| ?\pN+| ?[^\s\pL\pN]+|\s+`)
+
+type pair struct {
+	a, b string
+}
+
+// bpe is a byte-level byte-pair encoder
+type bpe struct {
+	ranks map[pair]int
+
+	mu    sync.Mutex
+	cache map[string]int
+}
+
+var (
+	loadOnce   sync.Once
+	loadedBPE  map[pair]int
+	loadBPEErr error
+)
+
+func newBPE() (Tokenizer, error) {
+	loadOnce.Do(func() {
+		loadedBPE, loadBPEErr = parseMerges(mergesData)
+	})
+	if loadBPEErr != nil {
+		return nil, loadBPEErr
+	}
+	return &bpe{ranks: loadedBPE, cache: make(map[string]int)}, nil
+}
+
+// parseMerges parses the embedded merge table into pair ranks
+func parseMerges(data string) (map[pair]int, error) {
+	ranks := make(map[pair]int)
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+
+		first, err := strconv.QuotedPrefix(line)
+		if err != nil {
+			return nil, fmt.Errorf("merges.txt:%d: %w", lineNo, err)
+		}
+		a, _ := strconv.Unquote(first)
+		b, err := strconv.Unquote(strings.TrimSpace(line[len(first):]))
+		if err != nil {
+			return nil, fmt.Errorf("merges.txt:%d: %w", lineNo, err)
+		}
+
+		ranks[pair{a, b}] = len(ranks)
+	}
+	return ranks, scanner.Err()
+}
+
+func (t *bpe) Name() string {
+	return BPE
+}
+
+func (t *bpe) Count(s string) int {
+	n := 0
+	for _, word := range pretokenize.FindAllString(s, -1) {
+		n += t.countWord(word)
+	}
+	return n
+}
+
+// countWord returns the number of tokens in a single pre-tokenized word
+func (t *bpe) countWord(word string) int {
+	t.mu.Lock()
+	n, ok := t.cache[word]
+	t.mu.Unlock()
+	if ok {
+		return n
+	}
+
+	n = len(t.encode(word))
+
+	t.mu.Lock()
+	t.cache[word] = n
+	t.mu.Unlock()
+	return n
+}
+
+// encode applies merges to word in rank order until none apply
+func (t *bpe) encode(word string) []string {
+	parts := make([]string, len(word))
+	for i := 0; i < len(word); i++ {
+		parts[i] = word[i : i+1]
+	}
+
+	for len(parts) > 1 {
+		best := -1
+		bestRank := len(t.ranks)
+		for i := 0; i < len(parts)-1; i++ {
+			if r, ok := t.ranks[pair{parts[i], parts[i+1]}]; ok && r < bestRank {
+				best, bestRank = i, r
+			}
+		}
+		if best < 0 {
+			break
+		}
+
+		merged := parts[best] + parts[best+1]
+		parts = append(parts[:best+1], parts[best+2:]...)
+		parts[best] = merged
+	}
+
+	return parts
+}
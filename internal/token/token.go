@@ -0,0 +1,59 @@
+// Package token estimates how many LLM tokens a piece of text occupies.
+//
+// Two tokenizers are available: a cheap byte/character heuristic and a
+// byte-pair encoder driven by an embedded merge table. Both produce
+// estimates; exact counts depend on the model consuming the snapshot.
+package token
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Tokenizer names accepted by New
+const (
+	Heuristic = "heuristic"
+	BPE       = "bpe"
+)
+
+// DefaultTokenizer is used when no tokenizer is configured
+const DefaultTokenizer = Heuristic
+
+// Tokenizer counts tokens in text
+type Tokenizer interface {
+	Name() string
+	Count(s string) int
+}
+
+// New returns the tokenizer registered under name
+func New(name string) (Tokenizer, error) {
+	switch strings.ToLower(name) {
+	case "", Heuristic:
+		return heuristic{}, nil
+	case BPE:
+		return newBPE()
+	default:
+		return nil, fmt.Errorf("unknown tokenizer %q (expected %q or %q)", name, Heuristic, BPE)
+	}
+}
+
+// CountLines counts tokens of lines joined by newlines
+func CountLines(t Tokenizer, lines []string) int {
+	return t.Count(strings.Join(lines, "\n"))
+}
+
+// ===== Heuristic =====
+
+// heuristic approximates one token per four characters, which holds
+// reasonably well for English prose and source code.
+type heuristic struct{}
+
+func (heuristic) Name() string {
+	return Heuristic
+}
+
+func (heuristic) Count(s string) int {
+	n := utf8.RuneCountInString(s)
+	return (n + 3) / 4
+}
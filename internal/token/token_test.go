@@ -0,0 +1,42 @@
+package token_test
+
+import (
+	"testing"
+
+	"github.com/neox5/snp/internal/token"
+)
+
+func TestNew_UnknownTokenizer(t *testing.T) {
+	if _, err := token.New("words"); err == nil {
+		t.Error("New should fail for unknown tokenizer")
+	}
+}
+
+func TestCount(t *testing.T) {
+	tests := []struct {
+		name      string
+		tokenizer string
+		text      string
+		min, max  int
+	}{
+		{name: "heuristic empty", tokenizer: token.Heuristic, text: "", min: 0, max: 0},
+		{name: "heuristic rounds up", tokenizer: token.Heuristic, text: "abcde", min: 2, max: 2},
+		{name: "bpe empty", tokenizer: token.BPE, text: "", min: 0, max: 0},
+		{name: "bpe common word", tokenizer: token.BPE, text: "func", min: 1, max: 1},
+		{name: "bpe code", tokenizer: token.BPE, text: "func main() {\n\tfmt.Println(\"hello\")\n}", min: 8, max: 20},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tok, err := token.New(tt.tokenizer)
+			if err != nil {
+				t.Fatalf("New failed: %v", err)
+			}
+
+			got := tok.Count(tt.text)
+			if got < tt.min || got > tt.max {
+				t.Errorf("Count(%q) = %d, want between %d and %d", tt.text, got, tt.min, tt.max)
+			}
+		})
+	}
+}
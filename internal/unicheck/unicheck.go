@@ -0,0 +1,187 @@
+// Package unicheck finds characters that make text read differently from
+// how it is parsed: bidirectional controls (Trojan Source), invisible
+// characters and homoglyphs mixed into Latin words.
+package unicheck
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Kinds of suspicious characters
+const (
+	Bidi      = "bidi"
+	Invisible = "invisible"
+	Homoglyph = "homoglyph"
+)
+
+// Kinds lists the kinds in report order
+var Kinds = []string{Bidi, Invisible, Homoglyph}
+
+// Finding is one suspicious character
+type Finding struct {
+	Line   int // 1-based
+	Column int // 1-based, in characters
+	Kind   string
+	Char   rune
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%d:%d %s %s", f.Line, f.Column, f.Kind, escape(f.Char))
+}
+
+// bidiControls reorder text for display without changing what compilers
+// and interpreters see
+var bidiControls = map[rune]bool{
+	'\u061C': true, // Arabic letter mark
+	'\u200E': true, // Left-to-right mark
+	'\u200F': true, // Right-to-left mark
+	'\u202A': true, // Left-to-right embedding
+	'\u202B': true, // Right-to-left embedding
+	'\u202C': true, // Pop directional formatting
+	'\u202D': true, // Left-to-right override
+	'\u202E': true, // Right-to-left override
+	'\u2066': true, // Left-to-right isolate
+	'\u2067': true, // Right-to-left isolate
+	'\u2068': true, // First strong isolate
+	'\u2069': true, // Pop directional isolate
+}
+
+// invisibleChars render as nothing
+var invisibleChars = map[rune]bool{
+	'\u00AD': true, // Soft hyphen
+	'\u180E': true, // Mongolian vowel separator
+	'\u200B': true, // Zero width space
+	'\u200C': true, // Zero width non-joiner
+	'\u200D': true, // Zero width joiner
+	'\u2060': true, // Word joiner
+	'\u2061': true, // Function application
+	'\u2062': true, // Invisible times
+	'\u2063': true, // Invisible separator
+	'\u2064': true, // Invisible plus
+	'\uFEFF': true, // Zero width no-break space (BOM)
+}
+
+// confusableScripts contain letters that look like Latin ones
+var confusableScripts = []*unicode.RangeTable{unicode.Cyrillic, unicode.Greek, unicode.Armenian}
+
+// kind returns the kind of a suspicious character, or "" if it is not one.
+// Homoglyphs are decided per word by Check.
+func kind(r rune) string {
+	switch {
+	case bidiControls[r]:
+		return Bidi
+	case invisibleChars[r], r >= 0xE0000 && r <= 0xE007F: // Tag characters
+		return Invisible
+	}
+	return ""
+}
+
+// isConfusable reports whether r is a letter of a script resembling Latin
+func isConfusable(r rune) bool {
+	return r > unicode.MaxASCII && unicode.IsLetter(r) && unicode.In(r, confusableScripts...)
+}
+
+// Check returns the suspicious characters in lines. Letters of Cyrillic,
+// Greek or Armenian count as homoglyphs only within words that also
+// contain ASCII letters, so text written in those scripts is not flagged.
+// A byte order mark at the very start is not flagged.
+func Check(lines []string) []Finding {
+	var findings []Finding
+	for i, line := range lines {
+		runes := []rune(line)
+		mixed := mixedWords(runes)
+		for j, r := range runes {
+			k := kind(r)
+			if k == "" && mixed[j] && isConfusable(r) {
+				k = Homoglyph
+			}
+			if k == "" || (r == '\uFEFF' && i == 0 && j == 0) {
+				continue
+			}
+			findings = append(findings, Finding{Line: i + 1, Column: j + 1, Kind: k, Char: r})
+		}
+	}
+	return findings
+}
+
+// mixedWords marks the runes of words mixing ASCII and confusable letters
+func mixedWords(runes []rune) []bool {
+	mixed := make([]bool, len(runes))
+	for start := 0; start < len(runes); {
+		if !isWordRune(runes[start]) {
+			start++
+			continue
+		}
+		end := start
+		ascii, confusable := false, false
+		for end < len(runes) && isWordRune(runes[end]) {
+			ascii = ascii || runes[end] <= unicode.MaxASCII && unicode.IsLetter(runes[end])
+			confusable = confusable || isConfusable(runes[end])
+			end++
+		}
+		for k := start; k < end && ascii && confusable; k++ {
+			mixed[k] = true
+		}
+		start = end
+	}
+	return mixed
+}
+
+// isWordRune reports whether r can be part of an identifier or word
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// EscapeLines escapes the suspicious characters of each line in place
+func EscapeLines(lines []string) {
+	for i, line := range lines {
+		lines[i] = Escape(line, Check([]string{line}))
+	}
+}
+
+// Describe counts findings per kind, e.g. "2 bidi, 1 homoglyph"
+func Describe(findings []Finding) string {
+	counts := make(map[string]int)
+	for _, f := range findings {
+		counts[f.Kind]++
+	}
+	var parts []string
+	for _, k := range Kinds {
+		if counts[k] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[k], k))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Escape renders the characters of findings in line as visible \uXXXX
+// escapes. findings must belong to this line.
+func Escape(line string, findings []Finding) string {
+	if len(findings) == 0 {
+		return line
+	}
+	cols := make(map[int]bool, len(findings))
+	for _, f := range findings {
+		cols[f.Column] = true
+	}
+
+	var b strings.Builder
+	for j, r := range []rune(line) {
+		if cols[j+1] {
+			b.WriteString(escape(r))
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// escape formats r as a Go escape sequence
+func escape(r rune) string {
+	if r > 0xFFFF {
+		return fmt.Sprintf(`\U%08X`, r)
+	}
+	return fmt.Sprintf(`\u%04X`, r)
+}
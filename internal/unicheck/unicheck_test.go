@@ -0,0 +1,45 @@
+package unicheck_test
+
+import (
+	"testing"
+
+	"github.com/neox5/snp/internal/unicheck"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want string // unicheck.Describe of the findings
+	}{
+		{"plain", `if isAdmin { return }`, ""},
+		{"bidi override", "/* \u202E } \u2066if (isAdmin)\u2069 \u2066 begin admins only */", "4 bidi"},
+		{"zero width", "access\u200BLevel := 1", "1 invisible"},
+		{"homoglyph", "func v\u0430lidate() {}", "1 homoglyph"},
+		{"cyrillic text", "// \u041F\u0440\u0438\u0432\u0435\u0442 world", ""},
+		{"tag character", "x := 1 \U000E0041", "1 invisible"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := unicheck.Describe(unicheck.Check([]string{tt.line})); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheck_LeadingBOM(t *testing.T) {
+	found := unicheck.Check([]string{"\uFEFFpackage main", "\uFEFF"})
+	if len(found) != 1 || found[0].Line != 2 || found[0].Column != 1 {
+		t.Errorf("findings = %v", found)
+	}
+}
+
+func TestEscapeLines(t *testing.T) {
+	lines := []string{"a\u202Eb v\u0430r", "ok"}
+	unicheck.EscapeLines(lines)
+	if lines[0] != `a\u202Eb v\u0430r` || lines[1] != "ok" {
+		t.Errorf("lines = %q", lines)
+	}
+}
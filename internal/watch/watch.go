@@ -0,0 +1,117 @@
+// Package watch polls a source tree and reports changes once they settle.
+package watch
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/neox5/snp/internal/file"
+)
+
+// Default timings
+const (
+	DefaultInterval = 500 * time.Millisecond
+	DefaultDebounce = 300 * time.Millisecond
+)
+
+// Options configures polling
+type Options struct {
+	Interval time.Duration // Time between scans
+	Debounce time.Duration // Quiet period after the last change before rebuilding
+}
+
+// Rebuild is called with the changed paths (forward-slash, relative to the
+// source directory) and returns the absolute paths it wrote, whose own
+// changes are not reported
+type Rebuild func(changed []string) (written []string)
+
+// Run scans the files selected by opts every Interval until ctx is done.
+// A burst of changes triggers one rebuild after no change was seen for
+// Debounce. Files in ignored directories are never looked at.
+func Run(ctx context.Context, opts file.CollectOptions, o Options, rebuild Rebuild) error {
+	absSourceDir, err := filepath.Abs(opts.SourceDir)
+	if err != nil {
+		return err
+	}
+
+	prev, err := file.Stamps(opts)
+	if err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(o.Interval)
+	defer ticker.Stop()
+
+	pending := make(map[string]bool)
+	var lastChange time.Time
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case now := <-ticker.C:
+			cur, err := file.Stamps(opts)
+			if err != nil {
+				return err
+			}
+			if changed := diff(prev, cur); len(changed) > 0 {
+				for _, p := range changed {
+					pending[p] = true
+				}
+				lastChange = now
+			}
+			prev = cur
+
+			if len(pending) == 0 || now.Sub(lastChange) < o.Debounce {
+				continue
+			}
+
+			paths := make([]string, 0, len(pending))
+			for p := range pending {
+				paths = append(paths, p)
+			}
+			sort.Strings(paths)
+			clear(pending)
+
+			written := rebuild(paths)
+
+			// Take the rebuild's own output into the baseline
+			if len(written) > 0 {
+				cur, err := file.Stamps(opts)
+				if err != nil {
+					return err
+				}
+				for _, w := range written {
+					rel, err := filepath.Rel(absSourceDir, w)
+					if err != nil {
+						continue
+					}
+					rel = filepath.ToSlash(rel)
+					if s, ok := cur[rel]; ok {
+						prev[rel] = s
+					} else {
+						delete(prev, rel)
+					}
+				}
+			}
+		}
+	}
+}
+
+// diff returns the paths added, removed or modified between two scans
+func diff(prev, cur map[string]file.Stamp) []string {
+	var changed []string
+	for p, s := range cur {
+		if old, ok := prev[p]; !ok || old != s {
+			changed = append(changed, p)
+		}
+	}
+	for p := range prev {
+		if _, ok := cur[p]; !ok {
+			changed = append(changed, p)
+		}
+	}
+	return changed
+}
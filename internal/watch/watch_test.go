@@ -0,0 +1,71 @@
+package watch_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/neox5/snp/internal/file"
+	"github.com/neox5/snp/internal/watch"
+)
+
+func TestRun_DebouncesAndIgnores(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("a.txt", "a\n")
+	write("node_modules/x.js", "x\n")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	rebuilds := make(chan []string, 10)
+	opts := file.CollectOptions{SourceDir: dir, OutputPath: filepath.Join(dir, "out.snp")}
+	done := make(chan error, 1)
+	go func() {
+		done <- watch.Run(ctx, opts, watch.Options{Interval: 10 * time.Millisecond, Debounce: 50 * time.Millisecond},
+			func(changed []string) []string {
+				rebuilds <- changed
+				write("out.snp", time.Now().String())
+				return []string{filepath.Join(dir, "out.snp")}
+			})
+	}()
+
+	// Let the initial scan finish
+	time.Sleep(30 * time.Millisecond)
+	write("node_modules/x.js", "changed\n")
+	write("a.txt", "b\n")
+	write("b.txt", "b\n")
+
+	select {
+	case got := <-rebuilds:
+		if want := []string{"a.txt", "b.txt"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("changed = %v, want %v", got, want)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no rebuild after changes")
+	}
+
+	// Neither the rebuild's own output nor ignored files trigger rebuilds
+	select {
+	case got := <-rebuilds:
+		t.Errorf("unexpected rebuild for %v", got)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
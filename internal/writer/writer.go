@@ -7,8 +7,9 @@ import (
 
 // LineTracker tracks the current line number while writing
 type LineTracker struct {
-	w           *bufio.Writer
-	currentLine int
+	w            *bufio.Writer
+	currentLine  int
+	bytesWritten int64
 }
 
 // NewLineTracker creates a new line tracking writer
@@ -21,16 +22,33 @@ func NewLineTracker(w io.Writer) *LineTracker {
 
 // WriteLine writes a line and increments line counter
 func (lt *LineTracker) WriteLine(s string) error {
-	if _, err := lt.w.WriteString(s + "\n"); err != nil {
+	n, err := lt.w.WriteString(s + "\n")
+	lt.bytesWritten += int64(n)
+	if err != nil {
 		return err
 	}
 	lt.currentLine++
 	return nil
 }
 
+// WriteAnnotatedLine writes a line with an annotation attached before or
+// after it. It counts as a single line.
+func (lt *LineTracker) WriteAnnotatedLine(s, note string, suffix bool) error {
+	return lt.WriteLine(Annotate(s, note, suffix))
+}
+
+// Annotate attaches note to line s as prefix or suffix, separated by " | "
+func Annotate(s, note string, suffix bool) string {
+	if suffix {
+		return s + " | " + note
+	}
+	return note + " | " + s
+}
+
 // WriteString writes without newline or tracking
 func (lt *LineTracker) WriteString(s string) error {
-	_, err := lt.w.WriteString(s)
+	n, err := lt.w.WriteString(s)
+	lt.bytesWritten += int64(n)
 	return err
 }
 
@@ -39,6 +57,11 @@ func (lt *LineTracker) CurrentLine() int {
 	return lt.currentLine
 }
 
+// BytesWritten returns the number of bytes written so far
+func (lt *LineTracker) BytesWritten() int64 {
+	return lt.bytesWritten
+}
+
 // Flush flushes the underlying buffer
 func (lt *LineTracker) Flush() error {
 	return lt.w.Flush()